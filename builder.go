@@ -0,0 +1,87 @@
+package jat
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Builder builds the requests with the same defaults,
+// useful when many tests share the same host, headers or query
+// Example:
+//
+//	b := NewBuilder().
+//		BaseURL("http://localhost:3000").
+//		DefaultHeader("Authorization", "Bearer token")
+//	r := b.Build(http.MethodGet, "/api/users").Unwrap()
+//	fmt.Println(r.URL)
+//	// Output: "http://localhost:3000/api/users"
+type Builder struct {
+	baseURL    string
+	pathPrefix string
+	header     http.Header
+	query      url.Values
+}
+
+// NewBuilder returns a *Builder without any default
+func NewBuilder() *Builder {
+	return &Builder{
+		header: http.Header{},
+		query:  url.Values{},
+	}
+}
+
+// BaseURL sets the base URL which the path of every request is resolved against,
+// see: WithBaseURL
+func (b *Builder) BaseURL(base string) *Builder {
+	b.baseURL = base
+
+	return b
+}
+
+// PathPrefix sets the prefix which is prepended to the path of every request,
+// see: WithPathPrefix
+func (b *Builder) PathPrefix(prefix string) *Builder {
+	b.pathPrefix = prefix
+
+	return b
+}
+
+// DefaultHeader adds the header to every request
+func (b *Builder) DefaultHeader(key, value string) *Builder {
+	b.header.Add(key, value)
+
+	return b
+}
+
+// DefaultQuery adds the query to every request
+func (b *Builder) DefaultQuery(key string, value interface{}) *Builder {
+	b.query.Add(key, formatValue(value, DefaultTimeFormat))
+
+	return b
+}
+
+// Build returns a new *RequestWrapper with all the defaults applied, the request has no body.
+// Each call returns an independent request, so modifying one does not affect the others
+func (b *Builder) Build(method, path string) *RequestWrapper {
+	rw := Wrap(NewRequest(method, path, http.NoBody))
+
+	if b.pathPrefix != "" {
+		rw.WithPathPrefix(b.pathPrefix)
+	}
+
+	if b.baseURL != "" {
+		rw.WithBaseURL(b.baseURL)
+	}
+
+	for key, values := range b.header {
+		for _, value := range values {
+			rw.Request.Header.Add(key, value)
+		}
+	}
+
+	if len(b.query) > 0 {
+		AddQueryValues(rw.Request, b.query)
+	}
+
+	return rw
+}
@@ -0,0 +1,67 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"net/http"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := jat.NewBuilder().
+		BaseURL("http://localhost:3000/").
+		DefaultHeader("Authorization", "Bearer token").
+		DefaultHeader("Accept", "application/json").
+		DefaultQuery("version", 2)
+
+	t.Run("apply defaults", func(t *testing.T) {
+		req := b.Build(http.MethodGet, "/api/users?type=code").Unwrap()
+
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Equal(t, "http://localhost:3000/api/users?type=code&version=2", req.URL.String())
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Equal(t, "application/json", req.Header.Get("Accept"))
+	})
+
+	t.Run("no body", func(t *testing.T) {
+		req := b.Build(http.MethodGet, "/api/users").Unwrap()
+
+		body, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Empty(t, body)
+		assert.Equal(t, int64(0), req.ContentLength)
+		assert.Empty(t, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("independent requests", func(t *testing.T) {
+		first := b.Build(http.MethodPost, "/api/users").
+			SetHeader("Authorization", "Bearer other").
+			AddQuery("version", 3).
+			Unwrap()
+
+		second := b.Build(http.MethodPost, "/api/users").Unwrap()
+
+		assert.Equal(t, "Bearer other", first.Header.Get("Authorization"))
+		assert.Equal(t, []string{"2", "3"}, first.URL.Query()["version"])
+
+		assert.Equal(t, "Bearer token", second.Header.Get("Authorization"))
+		assert.Equal(t, []string{"2"}, second.URL.Query()["version"])
+	})
+
+	t.Run("path prefix", func(t *testing.T) {
+		req := jat.NewBuilder().
+			BaseURL("http://localhost:3000").
+			PathPrefix("/api/v1").
+			Build(http.MethodGet, "/users").
+			Unwrap()
+
+		assert.Equal(t, "http://localhost:3000/api/v1/users", req.URL.String())
+	})
+
+	t.Run("without base url", func(t *testing.T) {
+		req := jat.NewBuilder().Build(http.MethodGet, "/api/users").Unwrap()
+
+		assert.Equal(t, "/api/users", req.URL.String())
+	})
+}
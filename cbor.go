@@ -0,0 +1,30 @@
+package jat
+
+import (
+	"net/http"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CBORCodec marshals the body with cbor.Marshal,
+// if the body is io.Reader, it will be used as raw CBOR
+var CBORCodec = rawCodec(ContentTypeCBOR, cbor.Marshal)
+
+// WithCBOR replaces the current body of the request with the CBOR encoded body
+// and sets the Content-Type to application/cbor unless it has been set before.
+// If body is io.Reader, it will be used as raw CBOR
+func WithCBOR(r *http.Request, body interface{}) {
+	WithEncoded(r, CBORCodec, body)
+}
+
+// WithCBOR replaces the current body of the wrapped request with the CBOR encoded body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithCBOR(body interface{}) *RequestWrapper {
+	defer rw.trace("WithCBOR", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithEncoded(rw.Request, CBORCodec, body)
+	})
+
+	return rw
+}
@@ -0,0 +1,37 @@
+package jat_test
+
+import (
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"testing"
+)
+
+func TestCBOR(t *testing.T) {
+	type reading struct {
+		Sensor string  `cbor:"sensor"`
+		Value  float64 `cbor:"value"`
+	}
+
+	t.Run("body from struct", func(t *testing.T) {
+		req := jat.WrapPOST("/readings", nil).
+			WithCBOR(reading{Sensor: "temp", Value: 21.5}).
+			Unwrap()
+
+		b := jat.RawBody(req)
+
+		var got reading
+		if assert.NoError(t, cbor.Unmarshal(b, &got)) {
+			assert.Equal(t, reading{Sensor: "temp", Value: 21.5}, got)
+		}
+
+		assert.Equal(t, int64(len(b)), req.ContentLength)
+		assert.Equal(t, jat.ContentTypeCBOR, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithCBOR(jat.POST("/", nil), make(chan int))
+		})
+	})
+}
@@ -0,0 +1,199 @@
+package jat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+)
+
+// Do sends the wrapped request with the client and returns the response.
+// The request built by httptest.NewRequest is a server request,
+// so it will be converted into a client request before sending:
+// the RequestURI is cleared, and the URL is made absolute using the Host of the request
+func (rw *RequestWrapper) Do(client *http.Client) (*http.Response, error) {
+	r, err := rw.TryUnwrap()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := toClientRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return rw.doWithRetry(client, req)
+}
+
+// WithRetry makes Do retry the request up to attempts times in total, waiting backoff between attempts.
+// Only network errors and the status codes set by WithRetryStatus are retried.
+// The body is re-read by GetBody on each attempt, so it must be populated
+func (rw *RequestWrapper) WithRetry(attempts int, backoff time.Duration) *RequestWrapper {
+	defer rw.trace("WithRetry", attempts, backoff)()
+
+	if attempts < 1 {
+		panic(fmt.Errorf("invalid retry attempts %d", attempts))
+	}
+
+	rw.retryAttempts = attempts
+	rw.retryBackoff = backoff
+
+	return rw
+}
+
+// WithRetryStatus sets the status codes of the response which are retried by WithRetry
+func (rw *RequestWrapper) WithRetryStatus(codes ...int) *RequestWrapper {
+	defer rw.trace("WithRetryStatus", codes)()
+
+	rw.retryStatuses = codes
+
+	return rw
+}
+
+// Attempts returns the number of attempts made by the last Do
+func (rw *RequestWrapper) Attempts() int {
+	return rw.attempts
+}
+
+func (rw *RequestWrapper) doWithRetry(client *http.Client, req *http.Request) (*http.Response, error) {
+	attempts := rw.retryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for i := 1; ; i++ {
+		rw.attempts = i
+
+		resp, err := client.Do(req)
+		if i == attempts || !rw.shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, errors.New("can not retry the request, GetBody is missing")
+			}
+
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("get body for retrying failed %v", err)
+			}
+			req.Body = body
+		}
+
+		time.Sleep(rw.retryBackoff)
+	}
+}
+
+func (rw *RequestWrapper) shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	for _, code := range rw.retryStatuses {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DoWith is the same with Do, but sends the request through the RoundTripper,
+// useful for stubbing the transport in tests
+func (rw *RequestWrapper) DoWith(rt http.RoundTripper) (*http.Response, error) {
+	return rw.Do(&http.Client{Transport: rt})
+}
+
+func toClientRequest(r *http.Request) (*http.Request, error) {
+	req := r.Clone(r.Context())
+
+	// client request must not have RequestURI
+	req.RequestURI = ""
+
+	req.URL = absoluteURL(r)
+	if req.URL.Host == "" {
+		return nil, errors.New("request URL must be absolute, the host is missing")
+	}
+
+	return req, nil
+}
+
+// absoluteURL returns a copy of the request URL,
+// the missing host and scheme are filled by the Host and TLS of the request
+func absoluteURL(r *http.Request) *url.URL {
+	u := *r.URL
+	if u.Host == "" {
+		u.Host = r.Host
+	}
+
+	if u.Scheme == "" {
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+
+	return &u
+}
+
+// Send is the same with Do, but wraps the response for inspecting with fluent interface
+// If an error occur, it will panic
+func (rw *RequestWrapper) Send(client *http.Client) *ResponseWrapper {
+	resp, err := rw.Do(client)
+	if err != nil {
+		panic(err)
+	}
+
+	return WrapResponse(resp)
+}
+
+// ServeHTTP serves the wrapped request with the handler
+// and returns the recorded response.
+// It is the most natural way to execute the request built by httptest.NewRequest
+func (rw *RequestWrapper) ServeHTTP(h http.Handler) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, rw.Unwrap())
+
+	return w
+}
+
+// Against starts a test server with the handler, sends the wrapped request to it
+// and returns the response. The URL of the request is rewritten to the server's address.
+// The server is closed before returning, so the body of the response is buffered
+func (rw *RequestWrapper) Against(h http.Handler) (*http.Response, error) {
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parse server URL failed %v", err)
+	}
+
+	rw.SetScheme(u.Scheme).SetHost(u.Host)
+
+	resp, err := rw.Do(server.Client())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed %v", err)
+	}
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(b))
+
+	return resp, nil
+}
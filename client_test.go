@@ -0,0 +1,218 @@
+package jat_test
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Method", r.Method)
+		w.Header().Set("X-URI", r.URL.RequestURI())
+
+		b, _ := ioutil.ReadAll(r.Body)
+		_, _ = w.Write(b)
+	})
+}
+
+func TestDo(t *testing.T) {
+	server := httptest.NewServer(echoHandler())
+	defer server.Close()
+
+	t.Run("send request", func(t *testing.T) {
+		resp, err := jat.WrapPOST(server.URL+"/users", map[string]string{"email": "foo@bar.com"}).
+			AddQuery("type", "code").
+			Do(server.Client())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, http.MethodPost, resp.Header.Get("X-Method"))
+		assert.Equal(t, "/users?type=code", resp.Header.Get("X-URI"))
+		assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(b))
+	})
+
+	t.Run("set scheme and host", func(t *testing.T) {
+		u, err := url.Parse(server.URL)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		resp, err := jat.WrapGET("/users").
+			SetScheme(u.Scheme).
+			SetHost(u.Host).
+			Do(server.Client())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		assert.Equal(t, "/users", resp.Header.Get("X-URI"))
+	})
+
+	t.Run("chunked body", func(t *testing.T) {
+		resp, err := jat.WrapPOST(server.URL+"/users", nil).
+			WithBodyString("hello").
+			WithChunked().
+			Do(server.Client())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		b, err := ioutil.ReadAll(resp.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello", string(b))
+	})
+
+	t.Run("building error", func(t *testing.T) {
+		_, err := jat.WrapPOST(server.URL+"/users", nil).
+			WithBody(make(chan int)).
+			Do(server.Client())
+
+		assert.Error(t, err)
+	})
+
+	t.Run("missing host", func(t *testing.T) {
+		rw := jat.WrapGET("/users")
+		rw.Request.Host = ""
+
+		_, err := rw.Do(server.Client())
+
+		assert.Error(t, err)
+	})
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestDoWith(t *testing.T) {
+	var got *http.Request
+	rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		got = r
+
+		return &http.Response{
+			StatusCode: http.StatusTeapot,
+			Body:       ioutil.NopCloser(strings.NewReader("stubbed")),
+			Request:    r,
+		}, nil
+	})
+
+	resp, err := jat.WrapGET("http://api.test/users").
+		AddQuery("type", "code").
+		DoWith(rt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "http://api.test/users?type=code", got.URL.String())
+	assert.Empty(t, got.RequestURI)
+}
+
+func TestRetry(t *testing.T) {
+	t.Run("retry status until success", func(t *testing.T) {
+		var bodies []string
+		rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(r.Body)
+			bodies = append(bodies, string(b))
+
+			status := http.StatusServiceUnavailable
+			if len(bodies) == 3 {
+				status = http.StatusCreated
+			}
+
+			return &http.Response{StatusCode: status, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		})
+
+		rw := jat.WrapPOST("http://api.test/users", nil).
+			WithBodyString("hello").
+			WithRetry(5, time.Millisecond).
+			WithRetryStatus(http.StatusServiceUnavailable)
+
+		resp, err := rw.DoWith(rt)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+		assert.Equal(t, 3, rw.Attempts())
+		assert.Equal(t, []string{"hello", "hello", "hello"}, bodies)
+	})
+
+	t.Run("retry network error until exhausted", func(t *testing.T) {
+		rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		})
+
+		rw := jat.WrapGET("http://api.test/users").WithRetry(2, 0)
+
+		_, err := rw.DoWith(rt)
+
+		assert.Error(t, err)
+		assert.Equal(t, 2, rw.Attempts())
+	})
+
+	t.Run("status not retried", func(t *testing.T) {
+		rt := roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+		})
+
+		rw := jat.WrapGET("http://api.test/users").WithRetry(3, 0)
+
+		resp, err := rw.DoWith(rt)
+		if assert.NoError(t, err) {
+			assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+		}
+		assert.Equal(t, 1, rw.Attempts())
+	})
+
+	t.Run("invalid attempts", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WrapGET("/users").WithRetry(0, time.Second)
+		})
+	})
+}
+
+func TestServeHTTP(t *testing.T) {
+	w := jat.WrapPOST("/users", map[string]string{"email": "foo@bar.com"}).
+		AddQuery("type", "code").
+		ServeHTTP(echoHandler())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.MethodPost, w.Header().Get("X-Method"))
+	assert.Equal(t, "/users?type=code", w.Header().Get("X-URI"))
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, w.Body.String())
+}
+
+func TestAgainst(t *testing.T) {
+	resp, err := jat.WrapPOST("/users", map[string]string{"email": "foo@bar.com"}).
+		AddQuery("type", "code").
+		Against(echoHandler())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/users?type=code", resp.Header.Get("X-URI"))
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(b))
+}
@@ -0,0 +1,108 @@
+package jat
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// Codec marshals the body and returns the bytes with its content type,
+// users can implement their own Codec for msgpack, protobuf...
+type Codec interface {
+	Marshal(v interface{}) ([]byte, string, error)
+}
+
+// CodecFunc is an adapter to allow the use of ordinary functions as Codec
+type CodecFunc func(v interface{}) ([]byte, string, error)
+
+// Marshal calls f(v)
+func (f CodecFunc) Marshal(v interface{}) ([]byte, string, error) {
+	return f(v)
+}
+
+var (
+	// JSONCodec marshals the body with json.Marshal
+	JSONCodec Codec = CodecFunc(func(v interface{}) ([]byte, string, error) {
+		b, err := json.Marshal(v)
+		return b, ContentTypeJSON, err
+	})
+
+	// XMLCodec marshals the body with xml.Marshal
+	XMLCodec Codec = CodecFunc(func(v interface{}) ([]byte, string, error) {
+		b, err := xml.Marshal(v)
+		return b, ContentTypeXML, err
+	})
+
+	// FormCodec url-encodes the body, which must be url.Values or map[string]interface{}
+	FormCodec Codec = CodecFunc(func(v interface{}) ([]byte, string, error) {
+		switch form := v.(type) {
+		case url.Values:
+			return []byte(form.Encode()), ContentTypeForm, nil
+		case map[string]interface{}:
+			return []byte(toValues(form).Encode()), ContentTypeForm, nil
+		default:
+			return nil, "", fmt.Errorf("form should be url.Values or map[string]interface{}, got %T", v)
+		}
+	})
+)
+
+// rawCodec returns a Codec which marshals the body with marshal,
+// if the body is io.Reader, it is read as the raw encoded body.
+// The content type is returned in both cases
+func rawCodec(contentType string, marshal func(v interface{}) ([]byte, error)) Codec {
+	return CodecFunc(func(v interface{}) ([]byte, string, error) {
+		if reader, ok := v.(io.Reader); ok {
+			b, err := ioutil.ReadAll(reader)
+			if closer, ok := reader.(io.Closer); ok {
+				closer.Close()
+			}
+
+			return b, contentType, err
+		}
+
+		b, err := marshal(v)
+		return b, contentType, err
+	})
+}
+
+// WithEncoded replaces the current body of the request with the body marshaled by the codec
+// and sets the Content-Type returned by the codec unless it has been set before
+func WithEncoded(r *http.Request, codec Codec, body interface{}) {
+	if err := tryWithEncoded(r, codec, body); err != nil {
+		panic(err)
+	}
+}
+
+// WithEncoded replaces the current body of the wrapped request with the body marshaled by the codec
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithEncoded(codec Codec, body interface{}) *RequestWrapper {
+	defer rw.trace("WithEncoded", codec, body)()
+
+	rw.replaceBody(func() error {
+		return tryWithEncoded(rw.Request, codec, body)
+	})
+
+	return rw
+}
+
+func tryWithEncoded(r *http.Request, codec Codec, body interface{}) error {
+	b, contentType, err := codec.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode body failed: %v, error: %v", body, err)
+	}
+
+	if err := TryWithBody(r, bytes.NewReader(b)); err != nil {
+		return err
+	}
+
+	if contentType != "" {
+		setDefaultContentType(r, contentType)
+	}
+
+	return nil
+}
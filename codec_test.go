@@ -0,0 +1,93 @@
+package jat_test
+
+import (
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEncoded(t *testing.T) {
+	type user struct {
+		XMLName xml.Name `xml:"user" json:"-"`
+		Email   string   `xml:"email" json:"email"`
+	}
+
+	upper := jat.CodecFunc(func(v interface{}) ([]byte, string, error) {
+		return []byte(strings.ToUpper(v.(string))), "text/plain", nil
+	})
+
+	tests := map[string]struct {
+		codec jat.Codec
+		body  interface{}
+
+		wanted            string
+		wantedContentType string
+	}{
+		"json": {
+			codec: jat.JSONCodec,
+			body:  user{Email: "foo@bar.com"},
+
+			wanted:            `{"email":"foo@bar.com"}`,
+			wantedContentType: jat.ContentTypeJSON,
+		},
+
+		"xml": {
+			codec: jat.XMLCodec,
+			body:  user{Email: "foo@bar.com"},
+
+			wanted:            "<user><email>foo@bar.com</email></user>",
+			wantedContentType: jat.ContentTypeXML,
+		},
+
+		"form from map": {
+			codec: jat.FormCodec,
+			body:  map[string]interface{}{"id": []int{1, 2}},
+
+			wanted:            "id=1&id=2",
+			wantedContentType: jat.ContentTypeForm,
+		},
+
+		"form from url.Values": {
+			codec: jat.FormCodec,
+			body:  url.Values{"email": {"foo@bar.com"}},
+
+			wanted:            "email=foo%40bar.com",
+			wantedContentType: jat.ContentTypeForm,
+		},
+
+		"custom codec": {
+			codec: upper,
+			body:  "hello",
+
+			wanted:            "HELLO",
+			wantedContentType: "text/plain",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/", nil).
+				WithEncoded(test.codec, test.body).
+				Unwrap()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+			assert.Equal(t, test.wantedContentType, req.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("invalid body", func(t *testing.T) {
+		_, err := jat.WrapPOST("/", nil).
+			WithEncoded(jat.FormCodec, "hello").
+			TryUnwrap()
+
+		assert.Error(t, err)
+	})
+}
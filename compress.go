@@ -0,0 +1,81 @@
+package jat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WithGzipBody is the same with WithCompressedBody using gzip encoding
+func WithGzipBody(r *http.Request, body interface{}) {
+	WithCompressedBody(r, "gzip", body)
+}
+
+func (rw *RequestWrapper) WithGzipBody(body interface{}) *RequestWrapper {
+	defer rw.trace("WithGzipBody", body)()
+
+	return rw.WithCompressedBody("gzip", body)
+}
+
+// WithCompressedBody replaces the current body of the request with the compressed body
+// and sets the Content-Encoding header. The supported encodings are gzip and deflate.
+// The body is handled the same as WithBody before compressing
+func WithCompressedBody(r *http.Request, enc string, body interface{}) {
+	if err := tryWithCompressedBody(r, enc, body); err != nil {
+		panic(err)
+	}
+}
+
+// WithCompressedBody replaces the current body of the wrapped request with the compressed body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithCompressedBody(enc string, body interface{}) *RequestWrapper {
+	defer rw.trace("WithCompressedBody", enc, body)()
+
+	rw.replaceBody(func() error {
+		return tryWithCompressedBody(rw.Request, enc, body)
+	})
+
+	return rw
+}
+
+func tryWithCompressedBody(r *http.Request, enc string, body interface{}) error {
+	reader, err := tryToReader(body)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+
+	var w io.WriteCloser
+	switch enc {
+	case "gzip":
+		w = gzip.NewWriter(buf)
+	case "deflate":
+		w = zlib.NewWriter(buf)
+	default:
+		return fmt.Errorf("unsupported encoding %q", enc)
+	}
+
+	if _, err := io.Copy(w, reader); err != nil {
+		return fmt.Errorf("compress body failed %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("compress body failed %v", err)
+	}
+
+	if err := TryWithBody(r, buf); err != nil {
+		return err
+	}
+
+	if _, ok := body.(io.Reader); !ok {
+		setDefaultContentType(r, ContentTypeJSON)
+	}
+
+	r.Header.Set("Content-Encoding", enc)
+
+	return nil
+}
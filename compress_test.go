@@ -0,0 +1,95 @@
+package jat_test
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestCompressedBody(t *testing.T) {
+	tests := map[string]struct {
+		f func(wrapper *jat.RequestWrapper)
+
+		newReader func(r io.Reader) (io.Reader, error)
+
+		wantedEncoding    string
+		wantedContentType string
+		wanted            string
+	}{
+		"gzip from map": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithGzipBody(map[string]string{"email": "foo@bar.com"})
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+
+			wantedEncoding:    "gzip",
+			wantedContentType: "application/json",
+			wanted:            `{"email":"foo@bar.com"}`,
+		},
+
+		"gzip from io.Reader": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithGzipBody(strings.NewReader("hello"))
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return gzip.NewReader(r)
+			},
+
+			wantedEncoding: "gzip",
+			wanted:         "hello",
+		},
+
+		"deflate": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithCompressedBody("deflate", map[string]string{"email": "foo@bar.com"})
+			},
+			newReader: func(r io.Reader) (io.Reader, error) {
+				return zlib.NewReader(r)
+			},
+
+			wantedEncoding:    "deflate",
+			wantedContentType: "application/json",
+			wanted:            `{"email":"foo@bar.com"}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wrapper := jat.WrapPOST("/", nil)
+			test.f(wrapper)
+
+			req := wrapper.Unwrap()
+
+			compressed, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(compressed)), req.ContentLength)
+
+			r, err := test.newReader(strings.NewReader(string(compressed)))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			b, err := ioutil.ReadAll(r)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, test.wantedEncoding, req.Header.Get("Content-Encoding"))
+			assert.Equal(t, test.wantedContentType, req.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		_, err := jat.WrapPOST("/", nil).
+			WithCompressedBody("br", "hello").
+			TryUnwrap()
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,85 @@
+package jat
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http/httputil"
+	"sort"
+	"strings"
+)
+
+// Dump renders the wrapped request as text, including the method, URL, headers and body,
+// it is useful for snapshot testing. The body is still readable after dumping
+// See: httputil.DumpRequest
+func (rw *RequestWrapper) Dump() string {
+	return rw.dump(true)
+}
+
+// DumpHead is the same with Dump but without the body, useful for large payloads
+func (rw *RequestWrapper) DumpHead() string {
+	return rw.dump(false)
+}
+
+// String is the same with Dump
+func (rw *RequestWrapper) String() string {
+	return rw.Dump()
+}
+
+func (rw *RequestWrapper) dump(body bool) string {
+	rw.finalize()
+
+	// the RequestURI set by httptest.NewRequest is not updated
+	// when the URL is modified, so it is dumped from the URL instead
+	req := *rw.Request
+	u := *rw.Request.URL
+	rw.separateQuery(&u)
+	req.URL = &u
+	req.RequestURI = u.RequestURI()
+
+	// httputil.DumpRequest closes the body, so a buffered copy is dumped instead
+	if body && rw.Request.Body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(rw.RawBody()))
+	}
+
+	b, err := httputil.DumpRequest(&req, body)
+	if err != nil {
+		panic(fmt.Errorf("dump request failed %v", err))
+	}
+
+	return string(b)
+}
+
+// CurlString renders the wrapped request as a curl command for replaying,
+// the relative URL is made absolute using the Host of the request, the same as Do
+func (rw *RequestWrapper) CurlString() string {
+	rw.finalize()
+
+	u := absoluteURL(rw.Request)
+	rw.separateQuery(u)
+
+	parts := []string{"curl", "-X", shellQuote(rw.Request.Method), shellQuote(u.String())}
+
+	keys := make([]string, 0, len(rw.Request.Header))
+	for key := range rw.Request.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range rw.Request.Header[key] {
+			parts = append(parts, "-H", shellQuote(key+": "+value))
+		}
+	}
+
+	if body := rw.RawBody(); len(body) > 0 {
+		parts = append(parts, "--data-binary", shellQuote(string(body)))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes s with single quotes, so no character is special to the shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
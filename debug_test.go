@@ -0,0 +1,51 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	rw := jat.WrapPOST("/users", nil).
+		AddQuery("type", "code").
+		SetHeader("Authorization", "Bearer token").
+		WithBodyString(`{"email":"foo@bar.com"}`)
+
+	wantedHead := "POST /users?type=code HTTP/1.1\r\n" +
+		"Host: example.com\r\n" +
+		"Authorization: Bearer token\r\n" +
+		"\r\n"
+
+	assert.Equal(t, wantedHead+`{"email":"foo@bar.com"}`, rw.Dump())
+	assert.Equal(t, wantedHead, rw.DumpHead())
+	assert.Equal(t, rw.Dump(), rw.String())
+
+	b, err := ioutil.ReadAll(rw.Unwrap().Body)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"email":"foo@bar.com"}`, string(b))
+}
+
+func TestCurlString(t *testing.T) {
+	t.Run("with body", func(t *testing.T) {
+		rw := jat.WrapPOST("/users", nil).
+			AddQuery("type", "code").
+			SetHeader("Authorization", "Bearer token").
+			SetHeader("X-Quote", "it's").
+			WithBodyString(`{"name":"O'Neil"}`)
+
+		wanted := `curl -X 'POST' 'http://example.com/users?type=code' ` +
+			`-H 'Authorization: Bearer token' ` +
+			`-H 'X-Quote: it'\''s' ` +
+			`--data-binary '{"name":"O'\''Neil"}'`
+
+		assert.Equal(t, wanted, rw.CurlString())
+	})
+
+	t.Run("without body", func(t *testing.T) {
+		rw := jat.WrapHEAD("https://localhost:3000/users")
+
+		assert.Equal(t, `curl -X 'HEAD' 'https://localhost:3000/users'`, rw.CurlString())
+	})
+}
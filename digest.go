@@ -0,0 +1,225 @@
+package jat
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// DigestChallenge is the challenge sent by the server
+// in the WWW-Authenticate header for HTTP Digest Authentication, see: RFC 7616
+type DigestChallenge struct {
+	Realm  string
+	Nonce  string
+	Opaque string
+
+	// Algorithm is one of MD5, MD5-sess, SHA-256, SHA-256-sess
+	// MD5 is used if it is empty
+	Algorithm string
+
+	// QOP is the comma separated list of quality of protection: auth, auth-int
+	// auth is preferred if both are supported
+	QOP string
+}
+
+// ParseDigestChallenge parses the value of the WWW-Authenticate header
+// Example: Digest realm="test", nonce="abc", qop="auth"
+func ParseDigestChallenge(header string) (DigestChallenge, error) {
+	const prefix = "Digest "
+	if !strings.HasPrefix(header, prefix) {
+		return DigestChallenge{}, fmt.Errorf("not a digest challenge %q", header)
+	}
+
+	var c DigestChallenge
+	for _, param := range splitDigestParams(header[len(prefix):]) {
+		i := strings.Index(param, "=")
+		if i < 0 {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimSpace(param[:i]))
+		value := strings.Trim(strings.TrimSpace(param[i+1:]), `"`)
+
+		switch key {
+		case "realm":
+			c.Realm = value
+		case "nonce":
+			c.Nonce = value
+		case "opaque":
+			c.Opaque = value
+		case "algorithm":
+			c.Algorithm = value
+		case "qop":
+			c.QOP = value
+		}
+	}
+
+	if c.Nonce == "" {
+		return DigestChallenge{}, fmt.Errorf("nonce is missing in digest challenge %q", header)
+	}
+
+	return c, nil
+}
+
+// splitDigestParams splits the params by comma, except the ones inside quotes
+func splitDigestParams(s string) []string {
+	var params []string
+
+	quoted, start := false, 0
+	for i, c := range s {
+		switch c {
+		case '"':
+			quoted = !quoted
+		case ',':
+			if !quoted {
+				params = append(params, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(params, s[start:])
+}
+
+// SetDigestAuth sets the request's Authorization header to use HTTP
+// Digest Authentication with the provided username, password and the challenge of the server.
+// The nonce count is increased every time the same nonce is used
+func SetDigestAuth(r *http.Request, username, password string, challenge DigestChallenge) {
+	setDigestAuth(r, r.URL.RequestURI(), username, password, challenge)
+}
+
+// SetDigestAuth sets the Authorization header of the wrapped request to use HTTP Digest Authentication,
+// the options applied when unwrapping are applied first, so the digest stays valid
+func (rw *RequestWrapper) SetDigestAuth(username, password string, challenge DigestChallenge) *RequestWrapper {
+	defer rw.trace("SetDigestAuth", username, redacted, challenge)()
+
+	rw.finalize()
+
+	// the separator of WithSemicolonQuery is only rewritten when unwrapping
+	u := *rw.Request.URL
+	rw.separateQuery(&u)
+	setDigestAuth(rw.Request, u.RequestURI(), username, password, challenge)
+
+	return rw
+}
+
+// setDigestAuth is the same with SetDigestAuth, but the uri is the request target
+// which may be different from the current URL of the request when it is sent
+func setDigestAuth(r *http.Request, uri, username, password string, challenge DigestChallenge) {
+	algorithm := challenge.Algorithm
+	if algorithm == "" {
+		algorithm = "MD5"
+	}
+
+	base := strings.ToUpper(algorithm)
+	sess := strings.HasSuffix(base, "-SESS")
+	base = strings.TrimSuffix(base, "-SESS")
+
+	var newHash func() hash.Hash
+	switch base {
+	case "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		panic(fmt.Errorf("unsupported digest algorithm %q", algorithm))
+	}
+
+	h := func(s string) string {
+		hh := newHash()
+		hh.Write([]byte(s))
+		return hex.EncodeToString(hh.Sum(nil))
+	}
+
+	qop := selectQOP(challenge.QOP)
+	cnonce := cnonceGenerator()
+	nc := fmt.Sprintf("%08x", nextNonceCount(challenge.Nonce))
+
+	ha1 := h(username + ":" + challenge.Realm + ":" + password)
+	if sess {
+		ha1 = h(ha1 + ":" + challenge.Nonce + ":" + cnonce)
+	}
+
+	a2 := r.Method + ":" + uri
+	if qop == "auth-int" {
+		a2 += ":" + h(string(RawBody(r)))
+	}
+	ha2 := h(a2)
+
+	var response string
+	if qop == "" {
+		response = h(ha1 + ":" + challenge.Nonce + ":" + ha2)
+	} else {
+		response = h(strings.Join([]string{ha1, challenge.Nonce, nc, cnonce, qop, ha2}, ":"))
+	}
+
+	params := []string{
+		fmt.Sprintf("username=%q", username),
+		fmt.Sprintf("realm=%q", challenge.Realm),
+		fmt.Sprintf("nonce=%q", challenge.Nonce),
+		fmt.Sprintf("uri=%q", uri),
+		fmt.Sprintf("algorithm=%s", algorithm),
+		fmt.Sprintf("response=%q", response),
+	}
+
+	if challenge.Opaque != "" {
+		params = append(params, fmt.Sprintf("opaque=%q", challenge.Opaque))
+	}
+
+	if qop != "" {
+		params = append(params,
+			fmt.Sprintf("qop=%s", qop),
+			fmt.Sprintf("nc=%s", nc),
+			fmt.Sprintf("cnonce=%q", cnonce),
+		)
+	}
+
+	r.Header.Set("Authorization", "Digest "+strings.Join(params, ", "))
+}
+
+func selectQOP(qop string) string {
+	selected := ""
+	for _, q := range strings.Split(qop, ",") {
+		switch strings.TrimSpace(q) {
+		case "auth":
+			return "auth"
+		case "auth-int":
+			selected = "auth-int"
+		}
+	}
+
+	return selected
+}
+
+// cnonceGenerator generates the client nonce, it is replaced in the tests
+// to reproduce the examples of the RFC
+var cnonceGenerator = newCnonce
+
+func newCnonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Errorf("generate cnonce failed %v", err))
+	}
+
+	return hex.EncodeToString(b)
+}
+
+var nonceCounter = struct {
+	sync.Mutex
+	counts map[string]int
+}{counts: map[string]int{}}
+
+func nextNonceCount(nonce string) int {
+	nonceCounter.Lock()
+	defer nonceCounter.Unlock()
+
+	nonceCounter.counts[nonce]++
+
+	return nonceCounter.counts[nonce]
+}
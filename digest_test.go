@@ -0,0 +1,182 @@
+package jat_test
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"hash"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseDigestChallenge(t *testing.T) {
+	c, err := jat.ParseDigestChallenge(`Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=SHA-256, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+
+	assert.NoError(t, err)
+	assert.Equal(t, jat.DigestChallenge{
+		Realm:     "http-auth@example.org",
+		Nonce:     "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+		Opaque:    "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+		Algorithm: "SHA-256",
+		QOP:       "auth, auth-int",
+	}, c)
+
+	_, err = jat.ParseDigestChallenge(`Basic realm="test"`)
+	assert.Error(t, err)
+}
+
+func TestDigestAuth(t *testing.T) {
+	username, password := "Mufasa", "Circle of Life"
+
+	tests := map[string]struct {
+		challenge jat.DigestChallenge
+		newHash   func() hash.Hash
+
+		wantedQOP string
+	}{
+		"md5 without qop": {
+			challenge: jat.DigestChallenge{Realm: "test", Nonce: "nonce-1"},
+			newHash:   md5.New,
+		},
+
+		"md5 with qop": {
+			challenge: jat.DigestChallenge{Realm: "test", Nonce: "nonce-2", QOP: "auth-int, auth", Opaque: "opaque"},
+			newHash:   md5.New,
+
+			wantedQOP: "auth",
+		},
+
+		"sha-256 with qop auth-int": {
+			challenge: jat.DigestChallenge{Realm: "test", Nonce: "nonce-3", QOP: "auth-int", Algorithm: "SHA-256"},
+			newHash:   sha256.New,
+
+			wantedQOP: "auth-int",
+		},
+
+		"sha-256-sess": {
+			challenge: jat.DigestChallenge{Realm: "test", Nonce: "nonce-4", QOP: "auth", Algorithm: "SHA-256-sess"},
+			newHash:   sha256.New,
+
+			wantedQOP: "auth",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/dir/index.html?type=code", nil).
+				WithBodyString("hello").
+				SetDigestAuth(username, password, test.challenge).
+				Unwrap()
+
+			params := parseDigestParams(t, req.Header.Get("Authorization"))
+
+			// there is no published example of -sess and auth-int,
+			// the response is checked against RFC 7616, section 3.4.1 to 3.4.3
+			h := func(s string) string {
+				hh := test.newHash()
+				hh.Write([]byte(s))
+				return hex.EncodeToString(hh.Sum(nil))
+			}
+
+			ha1 := h(username + ":" + test.challenge.Realm + ":" + password)
+			if strings.HasSuffix(test.challenge.Algorithm, "-sess") {
+				ha1 = h(ha1 + ":" + test.challenge.Nonce + ":" + params["cnonce"])
+			}
+
+			a2 := http.MethodPost + ":/dir/index.html?type=code"
+			if test.wantedQOP == "auth-int" {
+				a2 += ":" + h("hello")
+			}
+
+			wanted := h(ha1 + ":" + test.challenge.Nonce + ":" + h(a2))
+			if test.wantedQOP != "" {
+				wanted = h(ha1 + ":" + test.challenge.Nonce + ":" + params["nc"] + ":" + params["cnonce"] + ":" + test.wantedQOP + ":" + h(a2))
+			}
+
+			assert.Equal(t, username, params["username"])
+			assert.Equal(t, "/dir/index.html?type=code", params["uri"])
+			assert.Equal(t, test.wantedQOP, params["qop"])
+			assert.Equal(t, test.challenge.Opaque, params["opaque"])
+			assert.Equal(t, wanted, params["response"])
+		})
+	}
+
+	t.Run("rfc 7616 examples", func(t *testing.T) {
+		// See: RFC 7616, section 3.9.1
+		challenge := jat.DigestChallenge{
+			Realm:  "http-auth@example.org",
+			Nonce:  "7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v",
+			Opaque: "FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS",
+			QOP:    "auth, auth-int",
+		}
+
+		examples := map[string]string{
+			"MD5":     "8ca523f5e9506fed4657c9700eebdbec",
+			"SHA-256": "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		}
+
+		for algorithm, wanted := range examples {
+			restore := jat.SetDigestCnonce("f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ")
+
+			challenge.Algorithm = algorithm
+			req := jat.WrapGET("/dir/index.html").SetDigestAuth(username, password, challenge).Unwrap()
+			restore()
+
+			params := parseDigestParams(t, req.Header.Get("Authorization"))
+
+			assert.Equal(t, "auth", params["qop"], algorithm)
+			assert.Equal(t, "00000001", params["nc"], algorithm)
+			assert.Equal(t, wanted, params["response"], algorithm)
+		}
+	})
+
+	t.Run("space encoding", func(t *testing.T) {
+		req := jat.WrapGET("/x").
+			AddQuery("q", "a b").
+			WithSpaceEncoding(jat.SpacePercent).
+			SetDigestAuth(username, password, jat.DigestChallenge{Realm: "test", Nonce: "nonce-space"}).
+			Unwrap()
+
+		assert.Equal(t, "/x?q=a%20b", req.URL.RequestURI())
+		assert.Equal(t, req.URL.RequestURI(), parseDigestParams(t, req.Header.Get("Authorization"))["uri"])
+	})
+
+	t.Run("semicolon query", func(t *testing.T) {
+		req := jat.WrapGET("/x?a=1&b=2").
+			WithSemicolonQuery().
+			SetDigestAuth(username, password, jat.DigestChallenge{Realm: "test", Nonce: "nonce-semicolon"}).
+			Unwrap()
+
+		assert.Equal(t, "/x?a=1;b=2", req.URL.RequestURI())
+		assert.Equal(t, req.URL.RequestURI(), parseDigestParams(t, req.Header.Get("Authorization"))["uri"])
+	})
+
+	t.Run("nonce count", func(t *testing.T) {
+		challenge := jat.DigestChallenge{Realm: "test", Nonce: "nonce-count", QOP: "auth"}
+
+		first := jat.WrapGET("/").SetDigestAuth(username, password, challenge).Unwrap()
+		second := jat.WrapGET("/").SetDigestAuth(username, password, challenge).Unwrap()
+
+		assert.Equal(t, "00000001", parseDigestParams(t, first.Header.Get("Authorization"))["nc"])
+		assert.Equal(t, "00000002", parseDigestParams(t, second.Header.Get("Authorization"))["nc"])
+	})
+}
+
+func parseDigestParams(t *testing.T, header string) map[string]string {
+	t.Helper()
+
+	if !assert.True(t, strings.HasPrefix(header, "Digest ")) {
+		return nil
+	}
+
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(header, "Digest "), ", ") {
+		kv := strings.SplitN(param, "=", 2)
+		params[kv[0]] = strings.Trim(kv[1], `"`)
+	}
+
+	return params
+}
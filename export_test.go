@@ -0,0 +1,15 @@
+package jat
+
+// SetDigestCnonce makes SetDigestAuth use the fixed cnonce and resets the nonce counts,
+// it returns the function restoring the random cnonce
+func SetDigestCnonce(cnonce string) (restore func()) {
+	cnonceGenerator = func() string { return cnonce }
+
+	nonceCounter.Lock()
+	nonceCounter.counts = map[string]int{}
+	nonceCounter.Unlock()
+
+	return func() {
+		cnonceGenerator = newCnonce
+	}
+}
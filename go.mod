@@ -3,6 +3,11 @@ module github.com/victornm/jat
 go 1.13
 
 require (
+	github.com/fxamacker/cbor/v2 v2.4.0
+	github.com/getkin/kin-openapi v0.20.0
 	github.com/gorilla/mux v1.7.4
-	github.com/stretchr/testify v1.5.1
+	github.com/stretchr/testify v1.6.1
+	github.com/vmihailenco/msgpack/v5 v5.0.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -0,0 +1,43 @@
+package jat
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// HMACSignatureHeader is the header set by SignHMAC
+const HMACSignatureHeader = "X-Signature"
+
+// SignHMAC sets the X-Signature header to the hex encoded HMAC of the request using algo, e.g. sha256.New.
+// The signed message is a line "name:value" for each of the headers in the given order,
+// the name is lower-cased and multiple values are joined by comma, followed by the body:
+//
+//	content-type:application/json
+//	x-timestamp:1590000000
+//	{"id":1}
+func SignHMAC(r *http.Request, secret []byte, algo func() hash.Hash, headers ...string) {
+	var msg strings.Builder
+	for _, key := range headers {
+		msg.WriteString(strings.ToLower(key) + ":" + strings.Join(r.Header[http.CanonicalHeaderKey(key)], ",") + "\n")
+	}
+	msg.Write(RawBody(r))
+
+	h := hmac.New(algo, secret)
+	h.Write([]byte(msg.String()))
+
+	r.Header.Set(HMACSignatureHeader, hex.EncodeToString(h.Sum(nil)))
+}
+
+// SignHMAC signs the wrapped request with HMAC,
+// the options applied when unwrapping are applied first, so the signature stays valid
+func (rw *RequestWrapper) SignHMAC(secret []byte, algo func() hash.Hash, headers ...string) *RequestWrapper {
+	defer rw.trace("SignHMAC", headers)()
+
+	rw.finalize()
+	SignHMAC(rw.Request, secret, algo, headers...)
+
+	return rw
+}
@@ -0,0 +1,34 @@
+package jat_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"testing"
+)
+
+func TestSignHMAC(t *testing.T) {
+	secret := []byte("secret")
+
+	req := jat.WrapPOST("/orders", nil).
+		WithBody(map[string]int{"id": 1}).
+		SetHeader("X-Timestamp", "1590000000").
+		SignHMAC(secret, sha256.New, "Content-Type", "X-Timestamp").
+		Unwrap()
+
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte("content-type:application/json\nx-timestamp:1590000000\n{\"id\":1}"))
+
+	assert.Equal(t, hex.EncodeToString(h.Sum(nil)), req.Header.Get(jat.HMACSignatureHeader))
+	assert.Equal(t, `{"id":1}`, string(jat.RawBody(req)))
+
+	t.Run("no header", func(t *testing.T) {
+		req := jat.GET("/orders")
+		jat.SignHMAC(req, secret, sha256.New)
+
+		h := hmac.New(sha256.New, secret)
+		assert.Equal(t, hex.EncodeToString(h.Sum(nil)), req.Header.Get(jat.HMACSignatureHeader))
+	})
+}
@@ -0,0 +1,30 @@
+package jat
+
+import (
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgPackCodec marshals the body with msgpack.Marshal,
+// if the body is io.Reader, it will be used as raw MessagePack
+var MsgPackCodec = rawCodec(ContentTypeMsgPack, msgpack.Marshal)
+
+// WithMsgPack replaces the current body of the request with the MessagePack encoded body
+// and sets the Content-Type to application/msgpack unless it has been set before.
+// If body is io.Reader, it will be used as raw MessagePack
+func WithMsgPack(r *http.Request, body interface{}) {
+	WithEncoded(r, MsgPackCodec, body)
+}
+
+// WithMsgPack replaces the current body of the wrapped request with the MessagePack encoded body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithMsgPack(body interface{}) *RequestWrapper {
+	defer rw.trace("WithMsgPack", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithEncoded(rw.Request, MsgPackCodec, body)
+	})
+
+	return rw
+}
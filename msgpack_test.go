@@ -0,0 +1,48 @@
+package jat_test
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"github.com/vmihailenco/msgpack/v5"
+	"testing"
+)
+
+func TestMsgPack(t *testing.T) {
+	type user struct {
+		Email string `msgpack:"email"`
+	}
+
+	t.Run("body from struct", func(t *testing.T) {
+		req := jat.WrapPOST("/users", nil).
+			WithMsgPack(user{Email: "foo@bar.com"}).
+			Unwrap()
+
+		b := jat.RawBody(req)
+
+		var got map[string]interface{}
+		if assert.NoError(t, msgpack.Unmarshal(b, &got)) {
+			assert.Equal(t, map[string]interface{}{"email": "foo@bar.com"}, got)
+		}
+
+		assert.Equal(t, int64(len(b)), req.ContentLength)
+		assert.Equal(t, jat.ContentTypeMsgPack, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("codec with raw body", func(t *testing.T) {
+		raw, _ := msgpack.Marshal("foo")
+
+		req := jat.WrapPOST("/users", nil).
+			WithEncoded(jat.MsgPackCodec, bytes.NewReader(raw)).
+			Unwrap()
+
+		assert.Equal(t, raw, jat.RawBody(req))
+		assert.Equal(t, jat.ContentTypeMsgPack, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("invalid body", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithMsgPack(jat.POST("/", nil), make(chan int))
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package jat
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// ValidateAgainst checks the path, method, parameters and body of the request
+// against the operation with operationID in the OpenAPI spec.
+// It returns an error if the request does not match the operation or violates its schema,
+// useful for catching tests which drift from the contract.
+// Security requirements of the operation are not checked
+func ValidateAgainst(r *http.Request, spec *openapi3.Swagger, operationID string) error {
+	router := openapi3filter.NewRouter()
+	if err := router.AddSwagger(spec); err != nil {
+		return fmt.Errorf("load OpenAPI spec failed %v", err)
+	}
+
+	// validate a copy, so the body of the request is still readable
+	req := r.Clone(r.Context())
+	if r.Body != nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(RawBody(r)))
+	}
+
+	route, pathParams, err := router.FindRoute(req.Method, absoluteURL(req))
+	if err != nil {
+		return fmt.Errorf("request %s %s does not match any operation %v", req.Method, req.URL.Path, err)
+	}
+
+	if route.Operation.OperationID != operationID {
+		return fmt.Errorf("request %s %s matches operation %q, not %q",
+			req.Method, req.URL.Path, route.Operation.OperationID, operationID)
+	}
+
+	return openapi3filter.ValidateRequest(req.Context(), &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	})
+}
+
+// ValidateAgainst checks the wrapped request against the operation in the OpenAPI spec,
+// an error occurred when building is returned first
+func (rw *RequestWrapper) ValidateAgainst(spec *openapi3.Swagger, operationID string) error {
+	if err := rw.check(); err != nil {
+		return err
+	}
+
+	return ValidateAgainst(rw.Request, spec, operationID)
+}
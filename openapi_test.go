@@ -0,0 +1,91 @@
+package jat_test
+
+import (
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"testing"
+)
+
+func TestValidateAgainst(t *testing.T) {
+	spec, err := openapi3.NewSwaggerLoader().LoadSwaggerFromFile("testdata/openapi.yaml")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := map[string]struct {
+		wrapper     *jat.RequestWrapper
+		operationID string
+
+		wantedErr string
+	}{
+		"valid path and query": {
+			wrapper: jat.WrapGET("/users/:id").
+				SetParam("id", 1).
+				AddQuery("fields", "email"),
+			operationID: "getUser",
+		},
+
+		"valid body": {
+			wrapper:     jat.WrapPOST("/users", nil).WithBody(map[string]string{"email": "foo@bar.com"}),
+			operationID: "createUser",
+		},
+
+		"other operation": {
+			wrapper:     jat.WrapGET("/users/1"),
+			operationID: "createUser",
+
+			wantedErr: `matches operation "getUser", not "createUser"`,
+		},
+
+		"unknown path": {
+			wrapper:     jat.WrapGET("/courses"),
+			operationID: "getUser",
+
+			wantedErr: "does not match any operation",
+		},
+
+		"invalid path param": {
+			wrapper:     jat.WrapGET("/users/abc"),
+			operationID: "getUser",
+
+			wantedErr: "Parameter 'id' in path",
+		},
+
+		"invalid query": {
+			wrapper:     jat.WrapGET("/users/1").AddQuery("fields", "password"),
+			operationID: "getUser",
+
+			wantedErr: "Parameter 'fields' in query",
+		},
+
+		"missing required field": {
+			wrapper:     jat.WrapPOST("/users", nil).WithBody(map[string]string{"name": "foo"}),
+			operationID: "createUser",
+
+			wantedErr: "doesn't match the schema",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.wrapper.ValidateAgainst(spec, test.operationID)
+
+			if test.wantedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.wantedErr)
+			}
+		})
+	}
+
+	t.Run("body is still readable", func(t *testing.T) {
+		rw := jat.WrapPOST("/users", nil).WithBody(map[string]string{"email": "foo@bar.com"})
+
+		assert.NoError(t, rw.ValidateAgainst(spec, "createUser"))
+		assert.Equal(t, `{"email":"foo@bar.com"}`, string(rw.RawBody()))
+	})
+}
@@ -0,0 +1,49 @@
+package jat
+
+import "net/http"
+
+// Option configures the *RequestWrapper built by New
+type Option func(rw *RequestWrapper)
+
+// New returns a new *RequestWrapper of the method and target with the options applied in order.
+// The request has no body unless an option sets it.
+// It coexists with the fluent interface, so the result can still be chained:
+//
+//	New(http.MethodGet, "/users/:id", WithParamOpt("id", 1)).SetBearerAuth(token)
+func New(method, target string, opts ...Option) *RequestWrapper {
+	rw := WrapMethod(method, target, http.NoBody)
+
+	for _, opt := range opts {
+		opt(rw)
+	}
+
+	return rw
+}
+
+// WithHeaderOpt sets the header key to value, see: SetHeader
+func WithHeaderOpt(key, value string) Option {
+	return func(rw *RequestWrapper) {
+		rw.SetHeader(key, value)
+	}
+}
+
+// WithBodyOpt replaces the body of the request, see: WithBody
+func WithBodyOpt(body interface{}) Option {
+	return func(rw *RequestWrapper) {
+		rw.WithBody(body)
+	}
+}
+
+// WithQueryOpt adds the value to the query key, see: AddQuery
+func WithQueryOpt(key string, value interface{}) Option {
+	return func(rw *RequestWrapper) {
+		rw.AddQuery(key, value)
+	}
+}
+
+// WithParamOpt replaces the path param key with value, see: SetParam
+func WithParamOpt(key string, value interface{}) Option {
+	return func(rw *RequestWrapper) {
+		rw.SetParam(key, value)
+	}
+}
@@ -0,0 +1,42 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"net/http"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("without options", func(t *testing.T) {
+		req := jat.New(http.MethodGet, "/users").Unwrap()
+
+		assert.Equal(t, http.MethodGet, req.Method)
+		assert.Equal(t, "/users", req.URL.String())
+		assert.Equal(t, int64(0), req.ContentLength)
+	})
+
+	t.Run("with options", func(t *testing.T) {
+		opts := []jat.Option{
+			jat.WithParamOpt("id", 1),
+			jat.WithQueryOpt("type", "code"),
+			jat.WithHeaderOpt("X-Request-Id", "abc"),
+			jat.WithBodyOpt(map[string]string{"email": "foo@bar.com"}),
+		}
+
+		req := jat.New(http.MethodPut, "/users/:id", opts...).
+			SetBearerAuth("token").
+			Unwrap()
+
+		assert.Equal(t, "/users/1?type=code", req.URL.String())
+		assert.Equal(t, "abc", req.Header.Get("X-Request-Id"))
+		assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+		assert.Equal(t, `{"email":"foo@bar.com"}`, string(jat.RawBody(req)))
+	})
+
+	t.Run("option error", func(t *testing.T) {
+		_, err := jat.New(http.MethodPost, "/users", jat.WithBodyOpt(make(chan int))).TryUnwrap()
+
+		assert.Error(t, err)
+	})
+}
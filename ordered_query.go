@@ -0,0 +1,153 @@
+package jat
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// WithOrderedQuery keeps the query params of the wrapper in the order they are added
+// instead of sorting them by key like url.Values.Encode.
+// It affects AddQuery, AddQueryInt, AddQueryBool, AddQueryFloat, AddQueryArray, AddQueryString,
+// AddQueryValues, WithNestedQuery, SetQuery, SetQueryValues, DeleteQuery, WithQueryString
+// and WithParamsFromQuery of the wrapper. The values added at once by AddQueryValues
+// and WithNestedQuery are in the order of their keys.
+// WithQuery, WithQueryValues and WithQueryStruct always replace the query sorted by key
+func (rw *RequestWrapper) WithOrderedQuery() *RequestWrapper {
+	defer rw.trace("WithOrderedQuery")()
+
+	rw.orderedQuery = true
+
+	return rw
+}
+
+// updateOrderedQuery parses the current query of the wrapped request in order,
+// then replaces it with the result of f
+func (rw *RequestWrapper) updateOrderedQuery(f func(q orderedQuery) orderedQuery) {
+	q, err := parseOrderedQuery(unseparatedQuery(rw.Request.URL))
+	if err != nil {
+		rw.setErr(err)
+		return
+	}
+
+	rw.Request.URL.RawQuery = f(q).encode()
+}
+
+type queryPair struct {
+	key   string
+	value string
+}
+
+// orderedQuery is the same with url.Values
+// but keeps the order of the key-value pairs
+type orderedQuery []queryPair
+
+func parseOrderedQuery(query string) (orderedQuery, error) {
+	var q orderedQuery
+	for _, pair := range strings.Split(query, "&") {
+		if pair == "" {
+			continue
+		}
+
+		key, value := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			key, value = pair[:i], pair[i+1:]
+		}
+
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse query failed %v", err)
+		}
+
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse query failed %v", err)
+		}
+
+		q = append(q, queryPair{key: key, value: value})
+	}
+
+	return q, nil
+}
+
+func (q orderedQuery) add(key, value string) orderedQuery {
+	return append(q, queryPair{key: key, value: value})
+}
+
+// addValues adds the values in the order of their keys
+func (q orderedQuery) addValues(values url.Values) orderedQuery {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range values[key] {
+			q = q.add(key, value)
+		}
+	}
+
+	return q
+}
+
+// set replaces the value of the first pair with the key and removes the others,
+// the pair is added to the end if the key is not present
+func (q orderedQuery) set(key, value string) orderedQuery {
+	return q.setValues(key, []string{value})
+}
+
+// setValues is the same with set, but the first pair is replaced with a pair for each value
+func (q orderedQuery) setValues(key string, values []string) orderedQuery {
+	pairs := make(orderedQuery, 0, len(values))
+	for _, value := range values {
+		pairs = append(pairs, queryPair{key: key, value: value})
+	}
+
+	var result orderedQuery
+	found := false
+	for _, pair := range q {
+		if pair.key != key {
+			result = append(result, pair)
+			continue
+		}
+
+		if !found {
+			result = append(result, pairs...)
+			found = true
+		}
+	}
+
+	if !found {
+		result = append(result, pairs...)
+	}
+
+	return result
+}
+
+func (q orderedQuery) del(key string) orderedQuery {
+	var result orderedQuery
+	for _, pair := range q {
+		if pair.key != key {
+			result = append(result, pair)
+		}
+	}
+
+	return result
+}
+
+func (q orderedQuery) encode() string {
+	var buf strings.Builder
+	for i, pair := range q {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+
+		buf.WriteString(url.QueryEscape(pair.key))
+		buf.WriteByte('=')
+		buf.WriteString(url.QueryEscape(pair.value))
+	}
+
+	return buf.String()
+}
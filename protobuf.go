@@ -0,0 +1,37 @@
+package jat
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtobufCodec marshals the body with proto.Marshal, the body must be proto.Message
+var ProtobufCodec Codec = CodecFunc(func(v interface{}) ([]byte, string, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, "", fmt.Errorf("protobuf body should be proto.Message, got %T", v)
+	}
+
+	b, err := proto.Marshal(msg)
+	return b, ContentTypeProtobuf, err
+})
+
+// WithProtobuf replaces the current body of the request with the binary encoding of the message
+// and sets the Content-Type to application/x-protobuf unless it has been set before
+func WithProtobuf(r *http.Request, msg proto.Message) {
+	WithEncoded(r, ProtobufCodec, msg)
+}
+
+// WithProtobuf replaces the current body of the wrapped request with the binary encoding of the message
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithProtobuf(msg proto.Message) *RequestWrapper {
+	defer rw.trace("WithProtobuf", msg)()
+
+	rw.replaceBody(func() error {
+		return tryWithEncoded(rw.Request, ProtobufCodec, msg)
+	})
+
+	return rw
+}
@@ -0,0 +1,33 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"testing"
+)
+
+func TestProtobuf(t *testing.T) {
+	req := jat.WrapPOST("/users", nil).
+		WithProtobuf(wrapperspb.String("foo@bar.com")).
+		Unwrap()
+
+	b := jat.RawBody(req)
+
+	got := &wrapperspb.StringValue{}
+	if assert.NoError(t, proto.Unmarshal(b, got)) {
+		assert.Equal(t, "foo@bar.com", got.GetValue())
+	}
+
+	assert.Equal(t, int64(len(b)), req.ContentLength)
+	assert.Equal(t, jat.ContentTypeProtobuf, req.Header.Get("Content-Type"))
+
+	t.Run("codec", func(t *testing.T) {
+		_, err := jat.WrapPOST("/users", nil).
+			WithEncoded(jat.ProtobufCodec, "foo@bar.com").
+			TryUnwrap()
+
+		assert.EqualError(t, err, "encode body failed: foo@bar.com, error: protobuf body should be proto.Message, got string")
+	})
+}
@@ -2,14 +2,25 @@ package jat
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // NOTE:
@@ -18,252 +29,2865 @@ import (
 // This behavior is the same with httptest.NewRequest
 
 // NewRequest is the same with httptest.NewRequest if body is io.Reader
-// Otherwise, it will try to marshal body as JSON format
+// Otherwise, it will try to marshal body as JSON format and set the Content-Type to application/json,
+// a nil body is marshaled to null without the Content-Type, so another body can be set later.
 // if an error occur, it will panic
+// The body is buffered and GetBody is set, so the body can be read again
 func NewRequest(method, target string, body interface{}) *http.Request {
-	return httptest.NewRequest(method, target, toReader(body))
+	r, err := TryNewRequest(method, target, body)
+	if err != nil {
+		panic(err)
+	}
+
+	return r
+}
+
+// NewRequestURL is the same with NewRequest but uses a copy of the URL as is,
+// without the round trip through string parsing, so the query is never re-ordered or re-encoded.
+// The Host of the request is set to the host of the URL if it has one.
+// A nil body of GET and HEAD is the same with http.NoBody, the same as GET and HEAD
+func NewRequestURL(method string, u *url.URL, body interface{}) *http.Request {
+	if body == nil && (method == http.MethodGet || method == http.MethodHead) {
+		body = http.NoBody
+	}
+
+	r := NewRequest(method, "/", body)
+
+	copied := *u
+	r.URL = &copied
+	r.RequestURI = copied.RequestURI()
+
+	if copied.Host != "" {
+		r.Host = copied.Host
+	}
+
+	if copied.Scheme == "https" {
+		r.TLS = &tls.ConnectionState{
+			Version:           tls.VersionTLS12,
+			HandshakeComplete: true,
+			ServerName:        copied.Hostname(),
+		}
+	}
+
+	return r
+}
+
+// WrapURL wraps the request built by NewRequestURL
+func WrapURL(method string, u *url.URL, body interface{}) *RequestWrapper {
+	return wrapBody(NewRequestURL(method, u, body))
+}
+
+// TryNewRequest is the same with NewRequest
+// but returns the error instead of panicking
+func TryNewRequest(method, target string, body interface{}) (r *http.Request, err error) {
+	reader, err := tryToReader(body)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("read body failed %v", err)
+	}
+
+	if err := checkTarget(method, target); err != nil {
+		return nil, err
+	}
+
+	// httptest.NewRequest panics on invalid target
+	defer func() {
+		if v := recover(); v != nil {
+			r, err = nil, fmt.Errorf("invalid target %q of %s request: %v", target, method, v)
+		}
+	}()
+
+	r = httptest.NewRequest(method, target, bytes.NewReader(b))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(b)), nil
+	}
+
+	// the body is buffered, but closing the request body should still close the given one,
+	// which may hold resources, e.g. an open file
+	if closer, ok := body.(io.ReadCloser); ok && body != http.NoBody {
+		r.Body = &readCloser{Reader: r.Body, Closer: closer}
+	}
+
+	if _, ok := body.(io.Reader); !ok && body != nil {
+		setDefaultContentType(r, ContentTypeJSON)
+	}
+
+	return r, nil
+}
+
+// checkTarget returns an error with the method and the target if the target can not be parsed,
+// the message of httptest.NewRequest does not tell which request is invalid.
+// The authority form of CONNECT, e.g. example.com:443, is left to httptest.NewRequest
+func checkTarget(method, target string) error {
+	if method == http.MethodConnect && !strings.HasPrefix(target, "/") {
+		return nil
+	}
+
+	if _, err := url.ParseRequestURI(target); err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+
+		return fmt.Errorf("invalid target %q of %s request: %v", target, method, err)
+	}
+
+	return nil
+}
+
+// readCloser reads from the buffered body and closes the original one
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+func tryToReader(body interface{}) (io.Reader, error) {
+	return tryToReaderWithOptions(body, nil)
+}
+
+func tryToReaderWithOptions(body interface{}, opts *JSONOptions) (io.Reader, error) {
+	if reader, ok := body.(io.Reader); ok {
+		return reader, nil
+	}
+
+	b, err := marshalJSON(body, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %v, error: %v", body, err)
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// JSONOptions configures how the body is marshaled into JSON
+// The zero value is the same with json.Marshal
+type JSONOptions struct {
+	// DisableHTMLEscape stops escaping <, > and & in JSON strings
+	DisableHTMLEscape bool
+
+	// Prefix and Indent are used to indent the JSON, see: json.MarshalIndent
+	Prefix string
+	Indent string
+}
+
+func marshalJSON(v interface{}, opts *JSONOptions) ([]byte, error) {
+	if opts == nil {
+		return json.Marshal(v)
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(!opts.DisableHTMLEscape)
+	enc.SetIndent(opts.Prefix, opts.Indent)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	// json.Encoder always ends the output with a newline
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// RequestWrapper wraps *httpRequest for building with fluent interface
+// Example:
+// r := Wrap(GET("/api/users"))).
+// 		AddQuery("type", "code").
+//		Unwrap()
+// fmt.Println(r.URL.RequestURI())
+// Output: "/api/users?type=code"
+type RequestWrapper struct {
+	Request *http.Request
+
+	logger    Logger
+	hasLogger bool
+
+	// err is the first error occurred when building,
+	// it is returned by TryUnwrap
+	err error
+
+	paramStyle   ParamStyle
+	escapeParam  bool
+	strictParams bool
+
+	orderedQuery bool
+
+	jsonOptions *JSONOptions
+	charset     string
+
+	// bodyContentType is the Content-Type last written by a body builder,
+	// it is replaced along with the body as long as it is unchanged
+	bodyContentType string
+
+	spaceEncoding    SpaceEncoding
+	hasSpaceEncoding bool
+
+	semicolonQuery bool
+
+	cancel context.CancelFunc
+
+	strictBody bool
+	allowBody  bool
+
+	multipart *multipartForm
+
+	timeFormat string
+
+	traceEnabled bool
+	traceDepth   int
+	traces       []string
+
+	retryAttempts int
+	retryBackoff  time.Duration
+	retryStatuses []int
+	attempts      int
+}
+
+// Wrap wraps *httpRequest and returns a *RequestWrapper
+// for building with fluent interface
+func Wrap(r *http.Request) *RequestWrapper {
+	return &RequestWrapper{Request: r}
+}
+
+// wrapBody wraps the request built with a body by the package,
+// so its Content-Type is replaced along with the body, see replaceBody
+func wrapBody(r *http.Request) *RequestWrapper {
+	rw := Wrap(r)
+	rw.bodyContentType = r.Header.Get("Content-Type")
+
+	return rw
+}
+
+// Unwrap return the wrapped request
+// It similar to using rw.Request directly
+// but will log the final Request method and URL for debug
+// If an error occurred when building, it will panic
+func (rw *RequestWrapper) Unwrap() *http.Request {
+	if err := rw.check(); err != nil {
+		panic(err)
+	}
+
+	return rw.unwrap()
+}
+
+func (rw *RequestWrapper) unwrap() *http.Request {
+	rw.finalize()
+	rw.separateQuery(rw.Request.URL)
+
+	l := logger
+	if rw.hasLogger {
+		l = rw.logger
+	}
+
+	if l != nil {
+		l("[%s] %s\n", rw.Request.Method, rw.Request.URL)
+	}
+
+	return rw.Request
+}
+
+// finalize applies the options which depend on the final state of the request,
+// it is called before the request is used
+func (rw *RequestWrapper) finalize() {
+	rw.applyCharset()
+
+	if rw.hasSpaceEncoding {
+		WithSpaceEncoding(rw.Request, rw.spaceEncoding)
+	}
+}
+
+// Clone returns a deep copy of the wrapper,
+// modifying the clone will not affect the original one and vice versa.
+// The body is buffered, so both requests have their own readable body.
+// If WithTimeout has been called, the clone has its own context derived from the original one,
+// so Cancel of the clone does not cancel the original, but Cancel of the original cancels both
+func (rw *RequestWrapper) Clone() *RequestWrapper {
+	clone := *rw
+
+	ctx := rw.Request.Context()
+	if rw.cancel != nil {
+		ctx, clone.cancel = context.WithCancel(ctx)
+	}
+
+	clone.Request = rw.Request.Clone(ctx)
+	clone.traces = append([]string(nil), rw.traces...)
+
+	if rw.Request.Body != nil {
+		clone.Request.Body = ioutil.NopCloser(bytes.NewReader(RawBody(rw.Request)))
+	}
+
+	if rw.multipart != nil {
+		clone.multipart = &multipartForm{
+			boundary: rw.multipart.boundary,
+			fields:   rw.multipart.fields,
+			files:    append([]multipartFile(nil), rw.multipart.files...),
+		}
+	}
+
+	return &clone
+}
+
+// WithContext replaces the wrapped request with a shallow copy of it
+// with its context changed to ctx, see: http.Request.WithContext
+// The following calls will operate on the new request
+func (rw *RequestWrapper) WithContext(ctx context.Context) *RequestWrapper {
+	defer rw.trace("WithContext", ctx)()
+
+	rw.Request = rw.Request.WithContext(ctx)
+
+	return rw
+}
+
+// WithTimeout is the same with WithContext using context.WithTimeout of the current context
+// Call Cancel to release the resources of the context when the request is no longer used
+func (rw *RequestWrapper) WithTimeout(d time.Duration) *RequestWrapper {
+	defer rw.trace("WithTimeout", d)()
+
+	ctx, cancel := context.WithTimeout(rw.Request.Context(), d)
+
+	if prev := rw.cancel; prev != nil {
+		rw.cancel = func() {
+			cancel()
+			prev()
+		}
+	} else {
+		rw.cancel = cancel
+	}
+
+	return rw.WithContext(ctx)
+}
+
+// Cancel cancels the contexts created by WithTimeout
+// It is safe to call multiple times, or without calling WithTimeout
+func (rw *RequestWrapper) Cancel() {
+	if rw.cancel != nil {
+		rw.cancel()
+	}
+}
+
+// Reset re-initializes the wrapper with a new request of the method and target,
+// the same as New without options. All the state of the wrapper is cleared,
+// including the options and the error occurred when building.
+// It allows reusing the allocation of the wrapper, e.g. with sync.Pool
+func (rw *RequestWrapper) Reset(method, target string) *RequestWrapper {
+	rw.Cancel()
+
+	*rw = RequestWrapper{Request: Method(method, target, http.NoBody)}
+
+	return rw
+}
+
+// TryUnwrap is the same with Unwrap
+// but returns the first error occurred when building instead of panicking
+func (rw *RequestWrapper) TryUnwrap() (*http.Request, error) {
+	if err := rw.check(); err != nil {
+		return nil, err
+	}
+
+	return rw.unwrap(), nil
+}
+
+// check returns the error occurred when building
+// or the error of the checks enabled on the wrapper
+func (rw *RequestWrapper) check() error {
+	if rw.err != nil {
+		return rw.err
+	}
+
+	if rw.strictParams {
+		if err := checkParams(rw.Request, rw.style()); err != nil {
+			return err
+		}
+	}
+
+	if rw.strictBody && !rw.allowBody {
+		if err := checkBody(rw.Request); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Must checks that the wrapped request is sendable and panics with a descriptive error otherwise:
+// no error occurred when building, the method is a valid token, the URL is absolute with a host
+// or a relative path starting with a slash, and every path param has been replaced.
+// It returns the wrapper, so it can be used as a checkpoint in the chain
+func (rw *RequestWrapper) Must() *RequestWrapper {
+	if err := rw.validate(); err != nil {
+		panic(fmt.Errorf("request %s %s is not well-formed: %v", rw.Request.Method, rw.Request.URL, err))
+	}
+
+	return rw
+}
+
+func (rw *RequestWrapper) validate() error {
+	if err := rw.check(); err != nil {
+		return err
+	}
+
+	if !isToken(rw.Request.Method) {
+		return fmt.Errorf("method should be a valid HTTP token %q", rw.Request.Method)
+	}
+
+	u := rw.Request.URL
+	if u.IsAbs() && u.Host == "" {
+		return fmt.Errorf("absolute URL %q has no host", u)
+	}
+
+	if !u.IsAbs() && !strings.HasPrefix(u.Path, "/") {
+		return fmt.Errorf("relative path %q should start with a slash", u.Path)
+	}
+
+	return checkParams(rw.Request, rw.style())
+}
+
+func (rw *RequestWrapper) setErr(err error) {
+	if rw.err == nil {
+		rw.err = err
+	}
+}
+
+// Use applies fn to the wrapped request, it is the extension point
+// for the mutations without a method, e.g. signing or custom encoding:
+//
+//	WrapPOST("/orders", body).Use(sign).SetBearerAuth(token)
+func (rw *RequestWrapper) Use(fn func(r *http.Request)) *RequestWrapper {
+	defer rw.trace("Use")()
+
+	fn(rw.Request)
+
+	return rw
+}
+
+// When applies fn to the wrapper if cond is true, so the mutations can be toggled without breaking the chain:
+//
+//	WrapGET("/users").When(withAuth, func(rw *RequestWrapper) *RequestWrapper {
+//		return rw.SetBearerAuth(token)
+//	})
+//
+// It is not traced, the mutations of fn are traced instead
+func (rw *RequestWrapper) When(cond bool, fn func(rw *RequestWrapper) *RequestWrapper) *RequestWrapper {
+	if !cond {
+		return rw
+	}
+
+	return fn(rw)
+}
+
+// ForEach calls fn with the wrapper for each element of values, which should be a slice or an array:
+//
+//	WrapGET("/users").ForEach(ids, func(rw *RequestWrapper, id interface{}) {
+//		rw.AddQuery("id", id)
+//	})
+//
+// It is not traced, the mutations of fn are traced instead
+func (rw *RequestWrapper) ForEach(values interface{}, fn func(rw *RequestWrapper, value interface{})) *RequestWrapper {
+	v := reflect.ValueOf(values)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		rw.setErr(fmt.Errorf("values of ForEach should be a slice or an array, got %T", values))
+		return rw
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		fn(rw, v.Index(i).Interface())
+	}
+
+	return rw
+}
+
+// ===== strict body =====
+
+// WithStrictBody makes Unwrap panic and TryUnwrap return an error
+// if a body is attached to a GET or HEAD request, so the mistakes are caught early.
+// Use AllowBody to skip the check for intentionally malformed requests
+func (rw *RequestWrapper) WithStrictBody() *RequestWrapper {
+	defer rw.trace("WithStrictBody")()
+
+	rw.strictBody = true
+
+	return rw
+}
+
+// AllowBody skips the check of WithStrictBody for this wrapper,
+// it is the escape hatch for testing intentionally malformed requests
+func (rw *RequestWrapper) AllowBody() *RequestWrapper {
+	defer rw.trace("AllowBody")()
+
+	rw.allowBody = true
+
+	return rw
+}
+
+// checkBody returns an error if the request has a body but its method forbids it
+func checkBody(r *http.Request) error {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return nil
+	}
+
+	if r.ContentLength != 0 {
+		return fmt.Errorf("%s request should not have a body", r.Method)
+	}
+
+	return nil
+}
+
+// ===== logger =====
+
+// Logger logs the debug messages, log.Printf is a valid Logger
+type Logger func(format string, args ...interface{})
+
+var logger Logger = log.Printf
+
+// SetLogger replaces the logger used by all wrappers when unwrapping
+// Passing nil will disable the logging
+func SetLogger(l Logger) {
+	logger = l
+}
+
+// WithLogger replaces the logger used by this wrapper only
+// Passing nil will disable the logging of this wrapper
+func (rw *RequestWrapper) WithLogger(l Logger) *RequestWrapper {
+	defer rw.trace("WithLogger", l)()
+
+	rw.logger = l
+	rw.hasLogger = true
+
+	return rw
+}
+
+// ===== trace =====
+
+// EnableTrace makes the wrapper record every following mutation with its arguments,
+// the records are returned by Trace. A mutation calling another one is recorded once
+func (rw *RequestWrapper) EnableTrace() *RequestWrapper {
+	rw.traceEnabled = true
+
+	return rw
+}
+
+// Trace returns the mutations recorded since EnableTrace in order, e.g. AddQuery("type", "code")
+func (rw *RequestWrapper) Trace() []string {
+	return append([]string(nil), rw.traces...)
+}
+
+// redacted replaces the secret arguments in the trace, so they are not printed in the logs
+const redacted = "***"
+
+// sensitiveHeaders are the canonical keys of the headers whose values are redacted in the trace
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	http.CanonicalHeaderKey(DefaultAPIKeyHeader): true,
+}
+
+// redactHeader returns the value to trace for the header key
+func redactHeader(key, value string) string {
+	if sensitiveHeaders[http.CanonicalHeaderKey(key)] {
+		return redacted
+	}
+
+	return value
+}
+
+// redactHeaders returns a copy of the headers to trace
+func redactHeaders(headers http.Header) http.Header {
+	result := make(http.Header, len(headers))
+	for key, values := range headers {
+		result[key] = make([]string, len(values))
+		for i, value := range values {
+			result[key][i] = redactHeader(key, value)
+		}
+	}
+
+	return result
+}
+
+// redactHeaderMap is the same with redactHeaders for the single value headers
+func redactHeaderMap(headers map[string]string) map[string]string {
+	result := make(map[string]string, len(headers))
+	for key, value := range headers {
+		result[key] = redactHeader(key, value)
+	}
+
+	return result
+}
+
+// redactCookies returns the cookies to trace without their values
+func redactCookies(cookies ...*http.Cookie) []string {
+	result := make([]string, len(cookies))
+	for i, c := range cookies {
+		if c != nil {
+			result[i] = c.Name + "=" + redacted
+		}
+	}
+
+	return result
+}
+
+// trace records the call of the method if it is not called by another mutation,
+// the returned func must be deferred
+func (rw *RequestWrapper) trace(method string, args ...interface{}) func() {
+	if !rw.traceEnabled {
+		return func() {}
+	}
+
+	if rw.traceDepth == 0 {
+		formatted := make([]string, len(args))
+		for i, arg := range args {
+			if s, ok := arg.(string); ok {
+				formatted[i] = strconv.Quote(s)
+			} else {
+				formatted[i] = fmt.Sprintf("%v", arg)
+			}
+		}
+
+		rw.traces = append(rw.traces, method+"("+strings.Join(formatted, ", ")+")")
+	}
+
+	rw.traceDepth++
+
+	return func() {
+		rw.traceDepth--
+	}
+}
+
+// ===== method ====
+
+// GET returns a GET request without body
+func GET(target string) *http.Request {
+	return NewRequest(http.MethodGet, target, http.NoBody)
+}
+
+func WrapGET(target string) *RequestWrapper {
+	return Wrap(GET(target))
+}
+
+func POST(target string, body interface{}) *http.Request {
+	return NewRequest(http.MethodPost, target, body)
+}
+
+func WrapPOST(target string, body interface{}) *RequestWrapper {
+	return wrapBody(POST(target, body))
+}
+
+func PUT(target string, body interface{}) *http.Request {
+	return NewRequest(http.MethodPut, target, body)
+}
+
+func WrapPUT(target string, body interface{}) *RequestWrapper {
+	return wrapBody(PUT(target, body))
+}
+
+func PATCH(target string, body interface{}) *http.Request {
+	return NewRequest(http.MethodPatch, target, body)
+}
+
+func WrapPATCH(target string, body interface{}) *RequestWrapper {
+	return wrapBody(PATCH(target, body))
+}
+
+func DELETE(target string, body interface{}) *http.Request {
+	return NewRequest(http.MethodDelete, target, body)
+}
+
+func WrapDELETE(target string, body interface{}) *RequestWrapper {
+	return wrapBody(DELETE(target, body))
+}
+
+// HEAD returns a HEAD request without body
+func HEAD(target string) *http.Request {
+	return NewRequest(http.MethodHead, target, http.NoBody)
+}
+
+func WrapHEAD(target string) *RequestWrapper {
+	return Wrap(HEAD(target))
+}
+
+func OPTIONS(target string, body interface{}) *http.Request {
+	return NewRequest(http.MethodOptions, target, body)
+}
+
+func WrapOPTIONS(target string, body interface{}) *RequestWrapper {
+	return wrapBody(OPTIONS(target, body))
+}
+
+// Method returns a request with any method, example: PROPFIND, MKCOL
+// The method must be a valid HTTP token, if not, it will panic
+func Method(method, target string, body interface{}) *http.Request {
+	if !isToken(method) {
+		panic(fmt.Errorf("method should be a valid HTTP token %q", method))
+	}
+
+	return NewRequest(method, target, body)
+}
+
+func WrapMethod(method, target string, body interface{}) *RequestWrapper {
+	return wrapBody(Method(method, target, body))
+}
+
+// isToken reports whether s is a valid token, see: RFC 7230, section 3.2.6
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", c):
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// ===== body =====
+
+// Common values of the Content-Type header
+const (
+	ContentTypeJSON      = "application/json"
+	ContentTypeXML       = "application/xml"
+	ContentTypeForm      = "application/x-www-form-urlencoded"
+	ContentTypeMultipart = "multipart/form-data"
+	ContentTypeNDJSON    = "application/x-ndjson"
+	ContentTypeJSONAPI   = "application/vnd.api+json"
+	ContentTypeProtobuf  = "application/x-protobuf"
+	ContentTypeMsgPack   = "application/msgpack"
+	ContentTypeCBOR      = "application/cbor"
+	ContentTypeYAML      = "application/yaml"
+)
+
+// WithBody replaces the current body of the request with new body
+// Also replace the ContentLength and GetBody because the body has been changed
+// If body is not io.Reader, the Content-Type will be set to application/json
+// unless it has been set before
+func WithBody(r *http.Request, body interface{}) {
+	if err := TryWithBody(r, body); err != nil {
+		panic(err)
+	}
+}
+
+// TryWithBody is the same with WithBody
+// but returns the error instead of panicking
+func TryWithBody(r *http.Request, body interface{}) error {
+	return tryWithBody(r, body, nil)
+}
+
+func tryWithBody(r *http.Request, body interface{}, opts *JSONOptions) error {
+	if fn, ok := body.(func() io.Reader); ok {
+		WithBodyFunc(r, fn)
+		return nil
+	}
+
+	reader, err := tryToReaderWithOptions(body, opts)
+	if err != nil {
+		return err
+	}
+
+	req, err := TryNewRequest(r.Method, "/", reader)
+	if err != nil {
+		return err
+	}
+
+	r.Body = req.Body
+	r.GetBody = req.GetBody
+	r.ContentLength = req.ContentLength
+
+	if _, ok := body.(io.Reader); !ok {
+		setDefaultContentType(r, ContentTypeJSON)
+	}
+
+	return nil
+}
+
+// WithBodyFunc replaces the current body of the request with a lazy body,
+// fn is called to produce the body when it is read and again by GetBody,
+// so the body is never buffered and can be regenerated for retries.
+// The length of the body is unknown, so the ContentLength is set to -1.
+// WithBody also accepts a func() io.Reader and calls WithBodyFunc
+func WithBodyFunc(r *http.Request, fn func() io.Reader) {
+	r.Body = &lazyBody{fn: fn}
+	r.GetBody = func() (io.ReadCloser, error) {
+		return &lazyBody{fn: fn}, nil
+	}
+	r.ContentLength = -1
+}
+
+func (rw *RequestWrapper) WithBodyFunc(fn func() io.Reader) *RequestWrapper {
+	defer rw.trace("WithBodyFunc", fn)()
+
+	WithBodyFunc(rw.Request, fn)
+
+	return rw
+}
+
+// lazyBody calls fn on the first read,
+// it closes the produced reader if it is an io.Closer
+type lazyBody struct {
+	fn     func() io.Reader
+	reader io.Reader
+}
+
+func (b *lazyBody) Read(p []byte) (int, error) {
+	if b.reader == nil {
+		b.reader = b.fn()
+	}
+
+	return b.reader.Read(p)
+}
+
+func (b *lazyBody) Close() error {
+	if c, ok := b.reader.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// WithBody replaces the current body of the wrapped request
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithBody(body interface{}) *RequestWrapper {
+	defer rw.trace("WithBody", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithBody(rw.Request, body, rw.jsonOptions)
+	})
+
+	return rw
+}
+
+// WithJSONOptions configures how WithBody of the wrapper marshals the body into JSON
+func (rw *RequestWrapper) WithJSONOptions(opts JSONOptions) *RequestWrapper {
+	defer rw.trace("WithJSONOptions", opts)()
+
+	rw.jsonOptions = &opts
+
+	return rw
+}
+
+// WithBodyExcept replaces the current body of the request with the JSON of body
+// without the given top level keys. The body must be encoded as a JSON object,
+// it is useful for testing missing required fields without defining a new struct
+func WithBodyExcept(r *http.Request, body interface{}, keys ...string) {
+	if err := tryWithBodyExcept(r, body, nil, keys); err != nil {
+		panic(err)
+	}
+}
+
+func tryWithBodyExcept(r *http.Request, body interface{}, opts *JSONOptions, keys []string) error {
+	m, err := toJSONObject(body)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		delete(m, key)
+	}
+
+	return tryWithBody(r, m, opts)
+}
+
+// WithBodyExcept replaces the current body of the wrapped request
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithBodyExcept(body interface{}, keys ...string) *RequestWrapper {
+	defer rw.trace("WithBodyExcept", body, keys)()
+
+	rw.replaceBody(func() error {
+		return tryWithBodyExcept(rw.Request, body, rw.jsonOptions, keys)
+	})
+
+	return rw
+}
+
+// WithBodyMerge replaces the current body of the request with the JSON of base
+// after applying the overrides. The key of an override may use dot notation
+// to reach nested objects, e.g. "address.city", missing objects are created.
+// The base must be encoded as a JSON object
+func WithBodyMerge(r *http.Request, base interface{}, overrides map[string]interface{}) {
+	if err := tryWithBodyMerge(r, base, nil, overrides); err != nil {
+		panic(err)
+	}
+}
+
+func tryWithBodyMerge(r *http.Request, base interface{}, opts *JSONOptions, overrides map[string]interface{}) error {
+	m, err := toJSONObject(base)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(overrides))
+	for key := range overrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := setJSONPath(m, key, overrides[key]); err != nil {
+			return err
+		}
+	}
+
+	return tryWithBody(r, m, opts)
+}
+
+// WithBodyMerge replaces the current body of the wrapped request
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithBodyMerge(base interface{}, overrides map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithBodyMerge", base, overrides)()
+
+	rw.replaceBody(func() error {
+		return tryWithBodyMerge(rw.Request, base, rw.jsonOptions, overrides)
+	})
+
+	return rw
+}
+
+// setJSONPath sets the value at the dot separated path of the object
+func setJSONPath(m map[string]interface{}, path string, value interface{}) error {
+	parts := strings.Split(path, ".")
+
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part]
+		if !ok || next == nil {
+			child := map[string]interface{}{}
+			m[part] = child
+			m = child
+			continue
+		}
+
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("can not override %q, %q is not an object", path, part)
+		}
+		m = child
+	}
+
+	m[parts[len(parts)-1]] = value
+
+	return nil
+}
+
+// toJSONObject marshals the body and decodes it back into a map,
+// numbers are kept as json.Number so they are not changed when marshaling again
+func toJSONObject(body interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON body: %v, error: %v", body, err)
+	}
+
+	v, err := decodeJSON(b)
+	m, ok := v.(map[string]interface{})
+	if err != nil || !ok {
+		return nil, fmt.Errorf("body must be encoded as a JSON object: %s", b)
+	}
+
+	return m, nil
+}
+
+func decodeJSON(b []byte) (interface{}, error) {
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// WithCanonicalJSON replaces the current body of the request with the canonical JSON of body:
+// the body is marshaled, decoded into maps and slices, then marshaled again,
+// so the keys of every nested object are sorted. If body is io.Reader, it is read as raw JSON.
+// It makes the raw body stable for snapshot comparisons
+func WithCanonicalJSON(r *http.Request, body interface{}) {
+	if err := tryWithCanonicalJSON(r, body, nil); err != nil {
+		panic(err)
+	}
+}
+
+func tryWithCanonicalJSON(r *http.Request, body interface{}, opts *JSONOptions) error {
+	var (
+		b   []byte
+		err error
+	)
+
+	if reader, ok := body.(io.Reader); ok {
+		b, err = ioutil.ReadAll(reader)
+	} else {
+		b, err = json.Marshal(body)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %v, error: %v", body, err)
+	}
+
+	v, err := decodeJSON(b)
+	if err != nil {
+		return fmt.Errorf("invalid JSON body: %s, error: %v", b, err)
+	}
+
+	return tryWithBody(r, v, opts)
+}
+
+// WithCanonicalJSON replaces the current body of the wrapped request with the canonical JSON of body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithCanonicalJSON(body interface{}) *RequestWrapper {
+	defer rw.trace("WithCanonicalJSON", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithCanonicalJSON(rw.Request, body, rw.jsonOptions)
+	})
+
+	return rw
+}
+
+// WithBodyString replaces the current body of the request with the exact string,
+// without any encoding. It is useful for testing invalid payloads
+func WithBodyString(r *http.Request, body string) {
+	WithBody(r, strings.NewReader(body))
+}
+
+func (rw *RequestWrapper) WithBodyString(body string) *RequestWrapper {
+	defer rw.trace("WithBodyString", body)()
+
+	WithBodyString(rw.Request, body)
+
+	return rw
+}
+
+// WithBodyBytes replaces the current body of the request with the bytes,
+// without any encoding
+func WithBodyBytes(r *http.Request, body []byte) {
+	WithBody(r, bytes.NewReader(body))
+}
+
+func (rw *RequestWrapper) WithBodyBytes(body []byte) *RequestWrapper {
+	defer rw.trace("WithBodyBytes", body)()
+
+	WithBodyBytes(rw.Request, body)
+
+	return rw
+}
+
+// SetContentLength sets the ContentLength of the request without changing the body.
+// It intentionally allows a mismatch between the declared length and the actual body,
+// so users can test how handlers react to an incorrect Content-Length
+func SetContentLength(r *http.Request, n int64) {
+	r.ContentLength = n
+}
+
+func (rw *RequestWrapper) SetContentLength(n int64) *RequestWrapper {
+	defer rw.trace("SetContentLength", n)()
+
+	SetContentLength(rw.Request, n)
+
+	return rw
+}
+
+// WithChunked marks the request as using chunked transfer encoding,
+// the length of the body is unknown, so the ContentLength is set to -1.
+// The body is not changed, it is useful for simulating streaming uploads
+func WithChunked(r *http.Request) {
+	r.ContentLength = -1
+	r.TransferEncoding = []string{"chunked"}
+}
+
+func (rw *RequestWrapper) WithChunked() *RequestWrapper {
+	defer rw.trace("WithChunked")()
+
+	WithChunked(rw.Request)
+
+	return rw
+}
+
+// WithTrailer declares the trailer key in the Trailer header
+// and sets its value in the Trailer of the request.
+// When sending with a client, trailers are only sent with chunked transfer encoding
+func WithTrailer(r *http.Request, key, value string) {
+	key = http.CanonicalHeaderKey(key)
+
+	if r.Trailer == nil {
+		r.Trailer = http.Header{}
+	}
+
+	if _, declared := r.Trailer[key]; !declared {
+		r.Header.Add("Trailer", key)
+	}
+
+	r.Trailer.Set(key, value)
+}
+
+func (rw *RequestWrapper) WithTrailer(key, value string) *RequestWrapper {
+	defer rw.trace("WithTrailer", key, value)()
+
+	WithTrailer(rw.Request, key, value)
+
+	return rw
+}
+
+// RawBody reads and returns the current body of the request
+// The body is reset after reading, so the request is still readable,
+// and it is safe to call multiple times
+func RawBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		panic(fmt.Errorf("read body failed %v", err))
+	}
+
+	// keep closing the original body
+	var closer io.Closer = r.Body
+	if rc, ok := r.Body.(*readCloser); ok {
+		closer = rc.Closer
+	}
+	r.Body = &readCloser{Reader: bytes.NewReader(b), Closer: closer}
+
+	return b
+}
+
+func (rw *RequestWrapper) RawBody() []byte {
+	return RawBody(rw.Request)
+}
+
+// WithJSONFile replaces the current body of the request with the content of the JSON file,
+// useful for loading fixtures from testdata. The Content-Type is set to application/json
+// unless it has been set before. If the file can not be read or is not valid JSON, it will panic
+func WithJSONFile(r *http.Request, path string) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(fmt.Errorf("read JSON file %q failed %v", path, err))
+	}
+
+	if !json.Valid(b) {
+		panic(fmt.Errorf("invalid JSON file %q", path))
+	}
+
+	WithBody(r, bytes.NewReader(b))
+	setDefaultContentType(r, ContentTypeJSON)
+}
+
+func (rw *RequestWrapper) WithJSONFile(path string) *RequestWrapper {
+	defer rw.trace("WithJSONFile", path)()
+
+	rw.replaceBody(func() error {
+		WithJSONFile(rw.Request, path)
+		return nil
+	})
+
+	return rw
+}
+
+// setDefaultContentType sets the Content-Type of the request
+// only if it has not been set, so the one set by user is respected
+// The package functions can not tell who set it, a body builder of the wrapper
+// replaces the Content-Type written for the previous body, see replaceBody
+func setDefaultContentType(r *http.Request, contentType string) {
+	if r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+}
+
+// replaceBody replaces the body of the wrapped request by calling build.
+// The Content-Type written by the previous body builder is removed first,
+// so build sets the one of the new body, while a Content-Type set by the user is kept.
+// The previous Content-Type is restored if build fails or sets none, e.g. for a raw reader
+func (rw *RequestWrapper) replaceBody(build func() error) {
+	previous := rw.Request.Header.Get("Content-Type")
+	written := previous == "" || previous == rw.bodyContentType
+	if written {
+		rw.Request.Header.Del("Content-Type")
+	}
+
+	err := build()
+
+	contentType := rw.Request.Header.Get("Content-Type")
+	if err != nil || contentType == "" {
+		if previous != "" {
+			rw.Request.Header.Set("Content-Type", previous)
+		}
+
+		rw.setErr(err)
+		return
+	}
+
+	if written || contentType != previous {
+		rw.bodyContentType = contentType
+	}
+}
+
+// userContentType marks the Content-Type as set by the user if key is Content-Type,
+// so the body builders keep it even if it equals the one they wrote
+func (rw *RequestWrapper) userContentType(key string) {
+	if http.CanonicalHeaderKey(key) == "Content-Type" {
+		rw.bodyContentType = ""
+	}
+}
+
+// WithCharset makes the textual Content-Type set by the body builders carry the charset parameter,
+// e.g. application/json; charset=utf-8. It is applied when unwrapping,
+// a Content-Type which already has a charset is kept
+func (rw *RequestWrapper) WithCharset(charset string) *RequestWrapper {
+	defer rw.trace("WithCharset", charset)()
+
+	rw.charset = charset
+
+	return rw
+}
+
+func (rw *RequestWrapper) applyCharset() {
+	contentType := rw.Request.Header.Get("Content-Type")
+	if rw.charset == "" || contentType == "" {
+		return
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !isTextMediaType(mediaType) {
+		return
+	}
+
+	if _, ok := params["charset"]; ok {
+		return
+	}
+
+	params["charset"] = rw.charset
+	rw.Request.Header.Set("Content-Type", mime.FormatMediaType(mediaType, params))
+
+	if contentType == rw.bodyContentType {
+		rw.bodyContentType = rw.Request.Header.Get("Content-Type")
+	}
+}
+
+// isTextMediaType reports whether the charset parameter makes sense for the media type,
+// JSON:API is excluded because it forbids any media type parameter
+func isTextMediaType(mediaType string) bool {
+	switch mediaType {
+	case ContentTypeJSONAPI:
+		return false
+	case ContentTypeJSON, ContentTypeXML, ContentTypeForm, ContentTypeNDJSON:
+		return true
+	}
+
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") ||
+		strings.HasSuffix(mediaType, "+xml")
+}
+
+// ===== xml =====
+
+// WithXML replaces the current body of the request with the XML encoded body
+// and sets the Content-Type to application/xml unless it has been set before.
+// If body is io.Reader, it will be used as raw XML
+func WithXML(r *http.Request, body interface{}) {
+	if err := tryWithXML(r, body); err != nil {
+		panic(err)
+	}
+}
+
+// WithXML replaces the current body of the wrapped request with the XML encoded body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithXML(body interface{}) *RequestWrapper {
+	defer rw.trace("WithXML", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithXML(rw.Request, body)
+	})
+
+	return rw
+}
+
+func tryWithXML(r *http.Request, body interface{}) error {
+	reader, ok := body.(io.Reader)
+	if !ok {
+		b, err := xml.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("invalid XML body: %v, error: %v", body, err)
+		}
+
+		reader = bytes.NewReader(b)
+	}
+
+	if err := TryWithBody(r, reader); err != nil {
+		return err
+	}
+
+	setDefaultContentType(r, ContentTypeXML)
+
+	return nil
+}
+
+// ===== ndjson =====
+
+// WithNDJSON replaces the current body of the request with the newline-delimited JSON,
+// each item is marshaled into a single line,
+// then sets the Content-Type to application/x-ndjson unless it has been set before
+func WithNDJSON(r *http.Request, items ...interface{}) {
+	if err := tryWithNDJSON(r, items...); err != nil {
+		panic(err)
+	}
+}
+
+// WithNDJSON replaces the current body of the wrapped request with the newline-delimited JSON
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithNDJSON(items ...interface{}) *RequestWrapper {
+	defer rw.trace("WithNDJSON", items)()
+
+	rw.replaceBody(func() error {
+		return tryWithNDJSON(rw.Request, items...)
+	})
+
+	return rw
+}
+
+func tryWithNDJSON(r *http.Request, items ...interface{}) error {
+	lines := make([][]byte, 0, len(items))
+	for i, item := range items {
+		b, err := json.Marshal(item)
+		if err != nil {
+			return fmt.Errorf("invalid JSON item at index %d: %v, error: %v", i, item, err)
+		}
+
+		lines = append(lines, b)
+	}
+
+	if err := TryWithBody(r, bytes.NewReader(bytes.Join(lines, []byte("\n")))); err != nil {
+		return err
+	}
+
+	setDefaultContentType(r, ContentTypeNDJSON)
+
+	return nil
+}
+
+// ===== form =====
+
+// WithForm replaces the current body of the request with the url-encoded data
+// and sets the Content-Type to application/x-www-form-urlencoded unless it has been set before.
+// Slice values will be added as repeated keys, the same as url.Values.Encode
+func WithForm(r *http.Request, data map[string]interface{}) {
+	WithFormValues(r, toValues(data))
+}
+
+func (rw *RequestWrapper) WithForm(data map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithForm", data)()
+
+	rw.replaceBody(func() error {
+		WithForm(rw.Request, data)
+		return nil
+	})
+
+	return rw
+}
+
+// WithFormValues is the same with WithForm but the form is url.Values
+func WithFormValues(r *http.Request, form url.Values) {
+	WithBody(r, strings.NewReader(form.Encode()))
+	setDefaultContentType(r, ContentTypeForm)
+}
+
+func (rw *RequestWrapper) WithFormValues(form url.Values) *RequestWrapper {
+	defer rw.trace("WithFormValues", form)()
+
+	rw.replaceBody(func() error {
+		WithFormValues(rw.Request, form)
+		return nil
+	})
+
+	return rw
+}
+
+// WithIndexedForm replaces the current body of the request with the url-encoded items
+// using indexed bracket keys, the same as WithFormValues:
+//
+//	WithIndexedForm(r, "items", []map[string]interface{}{{"name": "a"}, {"name": "b"}})
+//	// items[0][name]=a&items[1][name]=b
+func WithIndexedForm(r *http.Request, prefix string, items []map[string]interface{}) {
+	form := url.Values{}
+	for i, item := range items {
+		for key, value := range item {
+			addNestedValue(form, fmt.Sprintf("%s[%d][%s]", prefix, i, key), value, DefaultTimeFormat)
+		}
+	}
+
+	WithFormValues(r, form)
+}
+
+func (rw *RequestWrapper) WithIndexedForm(prefix string, items []map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithIndexedForm", prefix, items)()
+
+	rw.replaceBody(func() error {
+		WithIndexedForm(rw.Request, prefix, items)
+		return nil
+	})
+
+	return rw
+}
+
+// AddFormField adds the field to the current form body of the request,
+// the form body is created if the request has no form body yet,
+// replacing the current body and its Content-Type.
+// It is useful for adding fields conditionally across a fluent chain
+func AddFormField(r *http.Request, key, value string) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), ContentTypeForm) {
+		// the Content-Type is set directly, so the next call merges into the new form body
+		form := url.Values{}
+		form.Add(key, value)
+		WithFormValues(r, form)
+		r.Header.Set("Content-Type", ContentTypeForm)
+		return
+	}
+
+	form, err := url.ParseQuery(string(RawBody(r)))
+	if err != nil {
+		panic(fmt.Errorf("parse form body failed %v", err))
+	}
+
+	form.Add(key, value)
+	WithFormValues(r, form)
+}
+
+func (rw *RequestWrapper) AddFormField(key, value string) *RequestWrapper {
+	defer rw.trace("AddFormField", key, value)()
+
+	previous := rw.Request.Header.Get("Content-Type")
+	AddFormField(rw.Request, key, value)
+
+	if contentType := rw.Request.Header.Get("Content-Type"); previous == rw.bodyContentType || contentType != previous {
+		rw.bodyContentType = contentType
+	}
+
+	return rw
+}
+
+func toValues(data map[string]interface{}) url.Values {
+	values := url.Values{}
+	for key, value := range data {
+		addFormValue(values, key, value, DefaultTimeFormat)
+	}
+
+	return values
+}
+
+// addFormValue adds the value or each element if it is a slice, time.Time is formatted with the layout
+func addFormValue(form url.Values, key string, value interface{}, layout string) {
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		form.Add(key, formatValue(value, layout))
+		return
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		form.Add(key, formatValue(v.Index(i).Interface(), layout))
+	}
+}
+
+// ===== multipart =====
+
+// File is a file part of a multipart/form-data body
+type File struct {
+	FieldName string
+	FileName  string
+	Content   io.Reader
+}
+
+// WithMultipart replaces the current body of the request with a multipart/form-data body
+// which contains the fields and the files, then sets the Content-Type with the generated boundary.
+// Fields are written in the order of their keys, slice values will be written as repeated fields
+func WithMultipart(r *http.Request, fields map[string]interface{}, files ...File) {
+	writeMultipart(r, "", fields, files...)
+}
+
+// WithMultipartBoundary is the same with WithMultipart but uses the fixed boundary
+// instead of a random one, so the body is deterministic for snapshot testing.
+// If the boundary is invalid, it will panic
+func WithMultipartBoundary(r *http.Request, boundary string, fields map[string]interface{}, files ...File) {
+	writeMultipart(r, boundary, fields, files...)
+}
+
+func writeMultipart(r *http.Request, boundary string, fields map[string]interface{}, files ...File) {
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	if boundary != "" {
+		if err := w.SetBoundary(boundary); err != nil {
+			panic(fmt.Errorf("invalid multipart boundary %q %v", boundary, err))
+		}
+	}
+
+	values := toValues(fields)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, value := range values[key] {
+			if err := w.WriteField(key, value); err != nil {
+				panic(fmt.Errorf("write field %q failed %v", key, err))
+			}
+		}
+	}
+
+	for _, f := range files {
+		part, err := w.CreateFormFile(f.FieldName, f.FileName)
+		if err != nil {
+			panic(fmt.Errorf("create file %q failed %v", f.FileName, err))
+		}
+
+		if _, err := io.Copy(part, f.Content); err != nil {
+			panic(fmt.Errorf("write file %q failed %v", f.FileName, err))
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		panic(fmt.Errorf("close multipart writer failed %v", err))
+	}
+
+	WithBody(r, body)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+}
+
+// WithMultipart replaces the regular fields of the multipart body
+// The files added by WithFile are kept
+func (rw *RequestWrapper) WithMultipart(fields map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithMultipart", fields)()
+
+	rw.multipartForm().fields = fields
+	rw.writeMultipart()
+
+	return rw
+}
+
+// WithFile adds a file part to the multipart body
+// Calling it multiple times will append more parts to the same body
+func (rw *RequestWrapper) WithFile(fieldName, fileName string, content io.Reader) *RequestWrapper {
+	defer rw.trace("WithFile", fieldName, fileName, content)()
+
+	b, err := ioutil.ReadAll(content)
+	if err != nil {
+		panic(fmt.Errorf("read file %q failed %v", fileName, err))
+	}
+
+	form := rw.multipartForm()
+	form.files = append(form.files, multipartFile{fieldName: fieldName, fileName: fileName, content: b})
+	rw.writeMultipart()
+
+	return rw
+}
+
+// WithMultipartBoundary sets the fixed boundary of the multipart body
+// instead of a random one, so the body is deterministic for snapshot testing
+func (rw *RequestWrapper) WithMultipartBoundary(boundary string) *RequestWrapper {
+	defer rw.trace("WithMultipartBoundary", boundary)()
+
+	form := rw.multipartForm()
+	form.boundary = boundary
+
+	if form.fields != nil || len(form.files) > 0 {
+		rw.writeMultipart()
+	}
+
+	return rw
+}
+
+// multipartForm holds the parts added through the wrapper,
+// so the body can be rebuilt whenever a new part is added
+type multipartForm struct {
+	boundary string
+	fields   map[string]interface{}
+	files    []multipartFile
+}
+
+type multipartFile struct {
+	fieldName string
+	fileName  string
+	content   []byte
+}
+
+func (rw *RequestWrapper) multipartForm() *multipartForm {
+	if rw.multipart == nil {
+		rw.multipart = &multipartForm{}
+	}
+
+	return rw.multipart
+}
+
+func (rw *RequestWrapper) writeMultipart() {
+	files := make([]File, 0, len(rw.multipart.files))
+	for _, f := range rw.multipart.files {
+		files = append(files, File{
+			FieldName: f.fieldName,
+			FileName:  f.fileName,
+			Content:   bytes.NewReader(f.content),
+		})
+	}
+
+	rw.replaceBody(func() error {
+		writeMultipart(rw.Request, rw.multipart.boundary, rw.multipart.fields, files...)
+		return nil
+	})
+}
+
+// ===== url =====
+
+// WithBaseURL resolves the URL of the request against the base URL,
+// the path of the base is kept even if the path of the request starts with a slash:
+// "https://api.test/v1" and "/users?type=code" result in "https://api.test/v1/users?type=code"
+// The Host of the request is also updated
+func WithBaseURL(r *http.Request, base string) {
+	u, err := url.Parse(base)
+	if err != nil {
+		panic(fmt.Errorf("parse base URL failed %v", err))
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+		if u.RawPath != "" {
+			u.RawPath += "/"
+		}
+	}
+
+	ref := *r.URL
+	ref.Path = strings.TrimPrefix(ref.Path, "/")
+	ref.RawPath = strings.TrimPrefix(ref.RawPath, "/")
+
+	r.URL = u.ResolveReference(&ref)
+	r.Host = r.URL.Host
+}
+
+func (rw *RequestWrapper) WithBaseURL(base string) *RequestWrapper {
+	defer rw.trace("WithBaseURL", base)()
+
+	WithBaseURL(rw.Request, base)
+
+	return rw
+}
+
+// WithPathPrefix prepends the prefix to the path of the request, joining the slashes cleanly:
+// "/api/v1/" and "/users" result in "/api/v1/users".
+// It does nothing if the path already starts with the prefix
+func WithPathPrefix(r *http.Request, prefix string) {
+	prefix = "/" + strings.Trim(prefix, "/")
+	if prefix == "/" || hasPathPrefix(r.URL.Path, prefix) {
+		return
+	}
+
+	if r.URL.RawPath != "" {
+		escaped := (&url.URL{Path: prefix}).EscapedPath()
+		r.URL.RawPath = joinPathPrefix(escaped, r.URL.RawPath)
+	}
+
+	r.URL.Path = joinPathPrefix(prefix, r.URL.Path)
+}
+
+func (rw *RequestWrapper) WithPathPrefix(prefix string) *RequestWrapper {
+	defer rw.trace("WithPathPrefix", prefix)()
+
+	WithPathPrefix(rw.Request, prefix)
+
+	return rw
+}
+
+// hasPathPrefix reports whether the path starts with the whole segments of the prefix,
+// so "/api/v10" does not start with "/api/v1"
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func joinPathPrefix(prefix, path string) string {
+	if path == "" {
+		return prefix
+	}
+
+	return prefix + "/" + strings.TrimPrefix(path, "/")
+}
+
+// SetScheme sets the scheme of the request URL, example: http, https
+func SetScheme(r *http.Request, scheme string) {
+	r.URL.Scheme = scheme
+}
+
+func (rw *RequestWrapper) SetScheme(scheme string) *RequestWrapper {
+	defer rw.trace("SetScheme", scheme)()
+
+	SetScheme(rw.Request, scheme)
+
+	return rw
+}
+
+// SetHost sets the host of the request URL and the Host of the request,
+// example: localhost:3000
+func SetHost(r *http.Request, host string) {
+	r.URL.Host = host
+	r.Host = host
+}
+
+func (rw *RequestWrapper) SetHost(host string) *RequestWrapper {
+	defer rw.trace("SetHost", host)()
+
+	SetHost(rw.Request, host)
+
+	return rw
+}
+
+// SetRequestHost sets the Host field of the request only, the URL is not changed.
+// Go's server moves the Host header into this field and removes it from the header,
+// so handlers and routers reading r.Host (virtual hosts, host-based routing)
+// never see the value set by SetHeader("Host", ...)
+func SetRequestHost(r *http.Request, host string) {
+	r.Host = host
+}
+
+func (rw *RequestWrapper) SetRequestHost(host string) *RequestWrapper {
+	defer rw.trace("SetRequestHost", host)()
+
+	SetRequestHost(rw.Request, host)
+
+	return rw
+}
+
+// SetFragment sets the fragment of the request URL, without the leading #.
+// The fragment is never sent to the server, but it is kept in the URL for inspecting
+func SetFragment(r *http.Request, fragment string) {
+	r.URL.Fragment = fragment
+}
+
+func (rw *RequestWrapper) SetFragment(fragment string) *RequestWrapper {
+	defer rw.trace("SetFragment", fragment)()
+
+	SetFragment(rw.Request, fragment)
+
+	return rw
+}
+
+// Path returns the current path of the wrapped request
+func (rw *RequestWrapper) Path() string {
+	return rw.Request.URL.Path
+}
+
+// URL returns a copy of the current URL of the wrapped request,
+// modifying it does not affect the request
+func (rw *RequestWrapper) URL() *url.URL {
+	u := *rw.Request.URL
+
+	return &u
+}
+
+// Query returns a parsed copy of the current query of the wrapped request,
+// modifying it does not affect the request
+func (rw *RequestWrapper) Query() url.Values {
+	return currentQuery(rw.Request)
+}
+
+// Header returns a copy of the current header of the wrapped request,
+// modifying it does not affect the request
+func (rw *RequestWrapper) Header() http.Header {
+	return rw.Request.Header.Clone()
+}
+
+// ===== path params =====
+
+// ParamStyle returns the regular expression matching the placeholder of the param key
+// Users can define their own style, example: /users/<id>
+type ParamStyle func(key string) string
+
+var (
+	// ColonStyle matches the placeholder like /users/:id, it is the default style
+	ColonStyle ParamStyle = func(key string) string {
+		return `:` + key + `\b`
+	}
+
+	// BraceStyle matches the placeholder like /users/{id}
+	BraceStyle ParamStyle = func(key string) string {
+		return `\{` + key + `\}`
+	}
+)
+
+func WithParam(r *http.Request, param map[string]interface{}) {
+	WithParamStyle(r, ColonStyle, param)
+}
+
+// WithParamStyle is the same with WithParam
+// but matches the placeholders with the given style
+func WithParamStyle(r *http.Request, style ParamStyle, param map[string]interface{}) {
+	for k, v := range param {
+		SetParamStyle(r, style, k, v)
+	}
+}
+
+func (rw *RequestWrapper) WithParam(param map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithParam", param)()
+
+	for k, v := range param {
+		rw.SetParam(k, v)
+	}
+
+	return rw
+}
+
+// WithParamsFromQuery moves the query values into the path:
+// each query key which has a placeholder in the path replaces it with its first value,
+// then the key is removed from the query
+func WithParamsFromQuery(r *http.Request) {
+	q := currentQuery(r)
+	for _, key := range queryParamKeys(r, ColonStyle) {
+		SetParam(r, key, q.Get(key))
+		DeleteQuery(r, key)
+	}
+}
+
+func (rw *RequestWrapper) WithParamsFromQuery() *RequestWrapper {
+	defer rw.trace("WithParamsFromQuery")()
+
+	q := currentQuery(rw.Request)
+	for _, key := range queryParamKeys(rw.Request, rw.style()) {
+		rw.SetParam(key, q.Get(key))
+		rw.DeleteQuery(key)
+	}
+
+	return rw
+}
+
+// queryParamKeys returns the sorted query keys which have a placeholder in the path
+func queryParamKeys(r *http.Request, style ParamStyle) []string {
+	validID := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+	var keys []string
+	for key := range currentQuery(r) {
+		if !validID.MatchString(key) {
+			continue
+		}
+
+		re, err := regexp.Compile(style(key))
+		if err != nil || !re.MatchString(r.URL.Path) {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// SetParam replaces every placeholder of the key in the path with the value,
+// the query of the request is never touched
+func SetParam(r *http.Request, key string, value interface{}) {
+	SetParamStyle(r, ColonStyle, key, value)
+}
+
+// SetParamStyle is the same with SetParam
+// but matches the placeholder with the given style
+func SetParamStyle(r *http.Request, style ParamStyle, key string, value interface{}) {
+	setParam(r, style, false, key, value)
+}
+
+// setParam replaces the placeholders of the key with the value,
+// if escape is true, the value is escaped by url.PathEscape in the encoded path
+func setParam(r *http.Request, style ParamStyle, escape bool, key string, value interface{}) {
+	// key should be a valid identifier, if not, panic
+	validID := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	if !validID.MatchString(key) {
+		panic(fmt.Errorf("param key should be a valid identifier %v", key))
+	}
+
+	expr := style(key)
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		panic(fmt.Errorf("compile regex failed, may be key %q contain invalid regex %v", key, err))
+	}
+
+	v := fmt.Sprint(value)
+	rawPath := r.URL.RawPath
+	if escape && rawPath == "" {
+		rawPath = r.URL.EscapedPath()
+	}
+
+	r.URL.Path = re.ReplaceAllStringFunc(r.URL.Path, func(s string) string {
+		return v
+	})
+
+	if escape {
+		v = url.PathEscape(v)
+	}
+
+	if rawPath != "" {
+		r.URL.RawPath = replaceEscapedParam(rawPath, re, v)
+	}
+}
+
+// replaceEscapedParam replaces the placeholders in the encoded path segment by segment.
+// Each segment is decoded before matching, so an encoded placeholder like %3Aid is found as well,
+// segments without placeholder keep their original encoding
+func replaceEscapedParam(rawPath string, re *regexp.Regexp, value string) string {
+	segments := strings.Split(rawPath, "/")
+	for i, seg := range segments {
+		decoded, err := url.PathUnescape(seg)
+		if err != nil {
+			segments[i] = re.ReplaceAllLiteralString(seg, value)
+			continue
+		}
+
+		locs := re.FindAllStringIndex(decoded, -1)
+		if len(locs) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range locs {
+			b.WriteString(url.PathEscape(decoded[last:loc[0]]))
+			b.WriteString(value)
+			last = loc[1]
+		}
+		b.WriteString(url.PathEscape(decoded[last:]))
+
+		segments[i] = b.String()
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func (rw *RequestWrapper) SetParam(key string, value interface{}) *RequestWrapper {
+	defer rw.trace("SetParam", key, value)()
+
+	setParam(rw.Request, rw.style(), rw.escapeParam, key, value)
+
+	return rw
+}
+
+// WithParamEscape sets whether the param values set by WithParam and SetParam of the wrapper
+// are escaped by url.PathEscape, so a value like "a/b" stays in a single path segment.
+// By default, the values are not escaped and inserted into the path as they are
+func (rw *RequestWrapper) WithParamEscape(escape bool) *RequestWrapper {
+	defer rw.trace("WithParamEscape", escape)()
+
+	rw.escapeParam = escape
+
+	return rw
+}
+
+// WithParamStyle sets the style used by WithParam and SetParam of the wrapper
+func (rw *RequestWrapper) WithParamStyle(style ParamStyle) *RequestWrapper {
+	defer rw.trace("WithParamStyle", style)()
+
+	rw.paramStyle = style
+
+	return rw
+}
+
+// WithStrictParams makes Unwrap panic and TryUnwrap return an error
+// if the path still contains the placeholders which have not been replaced,
+// it helps to catch the typos in param names
+func (rw *RequestWrapper) WithStrictParams() *RequestWrapper {
+	defer rw.trace("WithStrictParams")()
+
+	rw.strictParams = true
+
+	return rw
+}
+
+// checkParams returns an error if the path of the request
+// still contains the placeholders of the style
+func checkParams(r *http.Request, style ParamStyle) error {
+	re, err := regexp.Compile(style(`[A-Za-z_][A-Za-z0-9_]*`))
+	if err != nil {
+		return fmt.Errorf("compile regex failed %v", err)
+	}
+
+	if params := re.FindAllString(r.URL.Path, -1); len(params) > 0 {
+		return fmt.Errorf("path %q contains params which have not been replaced %v", r.URL.Path, params)
+	}
+
+	return nil
+}
+
+func (rw *RequestWrapper) style() ParamStyle {
+	if rw.paramStyle == nil {
+		return ColonStyle
+	}
+
+	return rw.paramStyle
+}
+
+// ===== query ====
+
+// DefaultTimeFormat is the layout used to format time.Time values in query and header
+const DefaultTimeFormat = time.RFC3339
+
+// WithTimeFormat changes the layout used to format time.Time values
+// in query and header of the wrapped request, the default is DefaultTimeFormat
+func (rw *RequestWrapper) WithTimeFormat(layout string) *RequestWrapper {
+	defer rw.trace("WithTimeFormat", layout)()
+
+	rw.timeFormat = layout
+
+	return rw
+}
+
+func (rw *RequestWrapper) format(value interface{}) string {
+	return formatValue(value, rw.layout())
+}
+
+// layout returns the time format of the wrapper, DefaultTimeFormat if it is not set
+func (rw *RequestWrapper) layout() string {
+	if rw.timeFormat == "" {
+		return DefaultTimeFormat
+	}
+
+	return rw.timeFormat
+}
+
+// formatValue formats time.Time with the layout and any other value with fmt.Sprint
+func formatValue(value interface{}, layout string) string {
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format(layout)
+	case *time.Time:
+		if v != nil {
+			return v.Format(layout)
+		}
+	}
+
+	return fmt.Sprint(value)
+}
+
+// Add adds the value to key. It appends to any existing
+// values associated with key.
+func AddQuery(r *http.Request, key string, value interface{}) {
+	q := currentQuery(r)
+
+	q.Add(key, formatValue(value, DefaultTimeFormat))
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) AddQuery(key string, value interface{}) *RequestWrapper {
+	defer rw.trace("AddQuery", key, value)()
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return q.add(key, rw.format(value))
+		})
+
+		return rw
+	}
+
+	AddQuery(rw.Request, key, rw.format(value))
+
+	return rw
+}
+
+// AddQueryInt adds the integer value to key, formatted in base 10
+func AddQueryInt(r *http.Request, key string, value int) {
+	AddQuery(r, key, strconv.Itoa(value))
+}
+
+func (rw *RequestWrapper) AddQueryInt(key string, value int) *RequestWrapper {
+	defer rw.trace("AddQueryInt", key, value)()
+
+	return rw.AddQuery(key, strconv.Itoa(value))
+}
+
+// AddQueryBool adds the boolean value to key as "true" or "false"
+func AddQueryBool(r *http.Request, key string, value bool) {
+	AddQuery(r, key, strconv.FormatBool(value))
+}
+
+func (rw *RequestWrapper) AddQueryBool(key string, value bool) *RequestWrapper {
+	defer rw.trace("AddQueryBool", key, value)()
+
+	return rw.AddQuery(key, strconv.FormatBool(value))
+}
+
+// AddQueryFloat adds the float value to key, formatted without exponent
+// and with the smallest number of digits that represents the value exactly,
+// e.g. 1000000 instead of 1e+06
+func AddQueryFloat(r *http.Request, key string, value float64) {
+	AddQuery(r, key, formatFloat(value))
+}
+
+func (rw *RequestWrapper) AddQueryFloat(key string, value float64) *RequestWrapper {
+	defer rw.trace("AddQueryFloat", key, value)()
+
+	return rw.AddQuery(key, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// AddQueryArray adds the values to key. It appends to any existing
+// values associated with key.
+func AddQueryArray(r *http.Request, key string, values ...interface{}) {
+	q := currentQuery(r)
+
+	for _, value := range values {
+		q.Add(key, formatValue(value, DefaultTimeFormat))
+	}
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) AddQueryArray(key string, values ...interface{}) *RequestWrapper {
+	defer rw.trace("AddQueryArray", key, values)()
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			for _, value := range values {
+				q = q.add(key, rw.format(value))
+			}
+
+			return q
+		})
+
+		return rw
+	}
+
+	formatted := make([]interface{}, len(values))
+	for i, value := range values {
+		formatted[i] = rw.format(value)
+	}
+
+	AddQueryArray(rw.Request, key, formatted...)
+
+	return rw
+}
+
+// Set sets the key to value. It replaces any existing
+// values.
+func SetQuery(r *http.Request, key string, value interface{}) {
+	q := currentQuery(r)
+
+	q.Set(key, formatValue(value, DefaultTimeFormat))
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) SetQuery(key string, value interface{}) *RequestWrapper {
+	defer rw.trace("SetQuery", key, value)()
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return q.set(key, rw.format(value))
+		})
+
+		return rw
+	}
+
+	SetQuery(rw.Request, key, rw.format(value))
+
+	return rw
+}
+
+// SetQueryValues sets the key to the values. It replaces any existing
+// values, setting no value removes the key
+func SetQueryValues(r *http.Request, key string, values ...interface{}) {
+	q := currentQuery(r)
+
+	q.Del(key)
+	for _, value := range values {
+		q.Add(key, formatValue(value, DefaultTimeFormat))
+	}
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) SetQueryValues(key string, values ...interface{}) *RequestWrapper {
+	defer rw.trace("SetQueryValues", key, values)()
+
+	formatted := make([]interface{}, len(values))
+	strs := make([]string, len(values))
+	for i, value := range values {
+		strs[i] = rw.format(value)
+		formatted[i] = strs[i]
+	}
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return q.setValues(key, strs)
+		})
+
+		return rw
+	}
+
+	SetQueryValues(rw.Request, key, formatted...)
+
+	return rw
+}
+
+// SpaceEncoding is how the spaces are encoded in the query
+type SpaceEncoding int
+
+const (
+	// SpacePlus encodes the spaces as +, the same as url.Values.Encode
+	SpacePlus SpaceEncoding = iota
+
+	// SpacePercent encodes the spaces as %20
+	SpacePercent
+)
+
+// WithSpaceEncoding rewrites the spaces in the current query of the request with the encoding
+func WithSpaceEncoding(r *http.Request, enc SpaceEncoding) {
+	switch enc {
+	case SpacePlus:
+		r.URL.RawQuery = strings.Replace(r.URL.RawQuery, "%20", "+", -1)
+	case SpacePercent:
+		r.URL.RawQuery = strings.Replace(r.URL.RawQuery, "+", "%20", -1)
+	}
+}
+
+// WithSpaceEncoding sets how the spaces are encoded in the query of the wrapped request,
+// it is applied when unwrapping, so the query can be changed afterwards
+func (rw *RequestWrapper) WithSpaceEncoding(enc SpaceEncoding) *RequestWrapper {
+	defer rw.trace("WithSpaceEncoding", enc)()
+
+	rw.spaceEncoding = enc
+	rw.hasSpaceEncoding = true
+
+	return rw
+}
+
+// WithSemicolonQuery rewrites the current query of the request to use ; instead of &
+// between the pairs, for the legacy servers which use ; as the separator.
+// The & in the keys and values are escaped, so they are not changed.
+// The other query functions still read the pairs, but write the query back with &,
+// so it should be the last change to the query
+func WithSemicolonQuery(r *http.Request) {
+	r.URL.RawQuery = strings.Replace(r.URL.RawQuery, "&", ";", -1)
+}
+
+// currentQuery parses the current query of the request like URL.Query,
+// but the pairs separated by ; are supported, so a query rewritten by WithSemicolonQuery
+// can still be changed. The invalid pairs are dropped
+func currentQuery(r *http.Request) url.Values {
+	q, _ := url.ParseQuery(unseparatedQuery(r.URL))
+	return q
+}
+
+// unseparatedQuery returns the raw query of u with & between all the pairs,
+// an unescaped ; is always a separator, since it is escaped in the keys and values
+func unseparatedQuery(u *url.URL) string {
+	return strings.Replace(u.RawQuery, ";", "&", -1)
+}
+
+// WithSemicolonQuery separates the pairs in the query of the wrapped request with ;,
+// it is applied when unwrapping, so the query can be changed afterwards
+func (rw *RequestWrapper) WithSemicolonQuery() *RequestWrapper {
+	defer rw.trace("WithSemicolonQuery")()
+
+	rw.semicolonQuery = true
+
+	return rw
+}
+
+// separateQuery rewrites the separator of the query in u if WithSemicolonQuery is set.
+// It is not a part of finalize since url.ParseQuery rejects ;,
+// so a dumped wrapper could not change its query anymore
+func (rw *RequestWrapper) separateQuery(u *url.URL) {
+	if rw.semicolonQuery {
+		u.RawQuery = strings.Replace(u.RawQuery, "&", ";", -1)
+	}
+}
+
+// DeleteQuery deletes the values associated with key.
+// It does nothing if the key is not present
+func DeleteQuery(r *http.Request, key string) {
+	q := currentQuery(r)
+
+	q.Del(key)
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) DeleteQuery(key string) *RequestWrapper {
+	defer rw.trace("DeleteQuery", key)()
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return q.del(key)
+		})
+
+		return rw
+	}
+
+	DeleteQuery(rw.Request, key)
+
+	return rw
+}
+
+// WithQuery replaces the current query of the request with new query
+func WithQuery(r *http.Request, query map[string][]interface{}) {
+	withQuery(r, query, DefaultTimeFormat)
+}
+
+func (rw *RequestWrapper) WithQuery(query map[string][]interface{}) *RequestWrapper {
+	defer rw.trace("WithQuery", query)()
+
+	withQuery(rw.Request, query, rw.layout())
+
+	return rw
+}
+
+func withQuery(r *http.Request, query map[string][]interface{}, layout string) {
+	q := url.Values{}
+	for key, values := range query {
+		for _, value := range values {
+			q.Add(key, formatValue(value, layout))
+		}
+	}
+
+	r.URL.RawQuery = q.Encode()
+}
+
+// WithQueryString replaces the current query of the request with new query
+func WithQueryString(r *http.Request, query string) {
+	if err := TryWithQueryString(r, query); err != nil {
+		panic(err)
+	}
+}
+
+// TryWithQueryString is the same with WithQueryString
+// but returns the error instead of panicking
+func TryWithQueryString(r *http.Request, query string) error {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("parse query failed %v", err)
+	}
+
+	r.URL.RawQuery = q.Encode()
+
+	return nil
+}
+
+// WithQueryString replaces the current query of the wrapped request
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithQueryString(query string) *RequestWrapper {
+	defer rw.trace("WithQueryString", query)()
+
+	if rw.orderedQuery {
+		q, err := parseOrderedQuery(query)
+		if err != nil {
+			rw.setErr(err)
+			return rw
+		}
+
+		rw.Request.URL.RawQuery = q.encode()
+
+		return rw
+	}
+
+	rw.setErr(TryWithQueryString(rw.Request, query))
+
+	return rw
+}
+
+// AddQueryString parses the raw query and adds its values to the current query of the request.
+// It appends to any existing values associated with the keys.
+func AddQueryString(r *http.Request, query string) {
+	if err := tryAddQueryString(r, query); err != nil {
+		panic(err)
+	}
+}
+
+func tryAddQueryString(r *http.Request, query string) error {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return fmt.Errorf("parse query failed %v", err)
+	}
+
+	AddQueryValues(r, q)
+
+	return nil
+}
+
+// AddQueryString adds the values of the raw query to the current query of the wrapped request
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) AddQueryString(query string) *RequestWrapper {
+	defer rw.trace("AddQueryString", query)()
+
+	if rw.orderedQuery {
+		added, err := parseOrderedQuery(query)
+		if err != nil {
+			rw.setErr(err)
+			return rw
+		}
+
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return append(q, added...)
+		})
+
+		return rw
+	}
+
+	rw.setErr(tryAddQueryString(rw.Request, query))
+
+	return rw
+}
+
+// WithQueryValues replaces the current query of the request with new query
+func WithQueryValues(r *http.Request, query url.Values) {
+	r.URL.RawQuery = query.Encode()
+}
+
+func (rw *RequestWrapper) WithQueryValues(query url.Values) *RequestWrapper {
+	defer rw.trace("WithQueryValues", query)()
+
+	WithQueryValues(rw.Request, query)
+
+	return rw
 }
 
-func toReader(body interface{}) io.Reader {
-	if reader, ok := body.(io.Reader); ok {
-		return reader
+// AddQueryValues adds the values to the current query of the request.
+// It appends to any existing values associated with the keys.
+func AddQueryValues(r *http.Request, query url.Values) {
+	q := currentQuery(r)
+	for key, values := range query {
+		for _, value := range values {
+			q.Add(key, value)
+		}
 	}
 
-	b, err := json.Marshal(body)
-	if err != nil {
-		panic(fmt.Errorf("invalid JSON body: %v, error: %v", body, err))
+	r.URL.RawQuery = q.Encode()
+}
+
+func (rw *RequestWrapper) AddQueryValues(query url.Values) *RequestWrapper {
+	defer rw.trace("AddQueryValues", query)()
+
+	if rw.orderedQuery {
+		rw.updateOrderedQuery(func(q orderedQuery) orderedQuery {
+			return q.addValues(query)
+		})
+
+		return rw
 	}
 
-	return bytes.NewReader(b)
+	AddQueryValues(rw.Request, query)
+
+	return rw
 }
 
-// RequestWrapper wraps *httpRequest for building with fluent interface
-// Example:
-// r := Wrap(GET("/api/users"))).
-// 		AddQuery("type", "code").
-//		Unwrap()
-// fmt.Println(r.URL.RequestURI())
-// Output: "/api/users?type=code"
-type RequestWrapper struct {
-	Request *http.Request
+// WithNestedQuery adds the values of m to the current query with bracketed keys,
+// nested maps and slices are supported:
+//
+//	WithNestedQuery(r, "filter", map[string]interface{}{
+//		"name": "foo",
+//		"age":  map[string]interface{}{"gt": 10},
+//		"tag":  []string{"a", "b"},
+//	})
+//	// filter[name]=foo&filter[age][gt]=10&filter[tag][]=a&filter[tag][]=b
+//
+// The brackets are escaped when encoding, the same as any other key
+func WithNestedQuery(r *http.Request, prefix string, m map[string]interface{}) {
+	AddQueryValues(r, nestedValues(prefix, m, DefaultTimeFormat))
 }
 
-// Wrap wraps *httpRequest and returns a *RequestWrapper
-// for building with fluent interface
-func Wrap(r *http.Request) *RequestWrapper {
-	return &RequestWrapper{Request: r}
+func (rw *RequestWrapper) WithNestedQuery(prefix string, m map[string]interface{}) *RequestWrapper {
+	defer rw.trace("WithNestedQuery", prefix, m)()
+
+	return rw.AddQueryValues(nestedValues(prefix, m, rw.layout()))
 }
 
-// Unwrap return the wrapped request
-// It similar to using rw.Request directly
-// but will log the final Request method and URL for debug
-func (rw *RequestWrapper) Unwrap() *http.Request {
-	// TODO: replace with user custom logger
-	log.Printf("[%s] %s\n", rw.Request.Method, rw.Request.URL)
-	return rw.Request
+func nestedValues(prefix string, m map[string]interface{}, layout string) url.Values {
+	values := url.Values{}
+	for key, value := range m {
+		addNestedValue(values, prefix+"["+key+"]", value, layout)
+	}
+
+	return values
 }
 
-// ===== method ====
+// addNestedValue adds the value with bracketed keys, time.Time is formatted with the layout
+func addNestedValue(values url.Values, key string, value interface{}, layout string) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Map:
+		for _, k := range v.MapKeys() {
+			addNestedValue(values, fmt.Sprintf("%s[%v]", key, k.Interface()), v.MapIndex(k).Interface(), layout)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			addNestedValue(values, key+"[]", v.Index(i).Interface(), layout)
+		}
+	default:
+		values.Add(key, formatValue(value, layout))
+	}
+}
 
-func GET(target string) *http.Request {
-	return NewRequest(http.MethodGet, target, nil)
+// WithQueryStruct replaces the current query of the request with the exported fields of v,
+// v must be a struct or a pointer to struct.
+// The key is read from the "url" tag, the same as the field name if there is no tag:
+//
+//	Name  string `url:"name"`          // name=...
+//	Age   int    `url:"age,omitempty"` // skipped if Age is zero
+//	IDs   []int  `url:"id"`            // id=1&id=2
+//	Token string `url:"-"`             // always skipped
+//
+// Embedded structs without tag are flattened
+func WithQueryStruct(r *http.Request, v interface{}) {
+	withQueryStruct(r, v, DefaultTimeFormat)
 }
 
-func WrapGET(target string) *RequestWrapper {
-	return Wrap(GET(target))
+func (rw *RequestWrapper) WithQueryStruct(v interface{}) *RequestWrapper {
+	defer rw.trace("WithQueryStruct", v)()
+
+	withQueryStruct(rw.Request, v, rw.layout())
+
+	return rw
 }
 
-func POST(target string, body interface{}) *http.Request {
-	return NewRequest(http.MethodPost, target, body)
+func withQueryStruct(r *http.Request, v interface{}, layout string) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Errorf("query should be a struct or a pointer to struct, got %T", v))
+	}
+
+	q := url.Values{}
+	addStructValues(q, rv, layout)
+
+	r.URL.RawQuery = q.Encode()
 }
 
-func WrapPOST(target string, body interface{}) *RequestWrapper {
-	return Wrap(POST(target, body))
+func addStructValues(q url.Values, rv reflect.Value, layout string) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		value := rv.Field(i)
+
+		tag := field.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := reflect.Indirect(value)
+			if embedded.Kind() == reflect.Struct {
+				addStructValues(q, embedded, layout)
+				continue
+			}
+		}
+
+		// unexported field
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if strings.Contains(opts, "omitempty") && value.IsZero() {
+			continue
+		}
+
+		if value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				continue
+			}
+
+			value = value.Elem()
+		}
+
+		addFormValue(q, name, value.Interface(), layout)
+	}
 }
 
-func PUT(target string, body interface{}) *http.Request {
-	return NewRequest(http.MethodPut, target, body)
+// ===== header =====
+
+// AddHeader adds the key, value pair to the header of the request.
+// See: http.Header.Add
+func AddHeader(r *http.Request, key, value string) {
+	r.Header.Add(key, value)
 }
 
-func WrapPUT(target string, body interface{}) *RequestWrapper {
-	return Wrap(PUT(target, body))
+func (rw *RequestWrapper) AddHeader(key, value string) *RequestWrapper {
+	defer rw.trace("AddHeader", key, redactHeader(key, value))()
+
+	AddHeader(rw.Request, key, value)
+	rw.userContentType(key)
+	return rw
 }
 
-func PATCH(target string, body interface{}) *http.Request {
-	return NewRequest(http.MethodPatch, target, body)
+// SetHeader sets the request's header entries associated with key to the
+// single element value. It replaces any existing values
+// associated with key.
+// See: http.Header.Set
+func SetHeader(r *http.Request, key, value string) {
+	r.Header.Set(key, value)
 }
 
-func WrapPATCH(target string, body interface{}) *RequestWrapper {
-	return Wrap(PATCH(target, body))
+func (rw *RequestWrapper) SetHeader(key, value string) *RequestWrapper {
+	defer rw.trace("SetHeader", key, redactHeader(key, value))()
+
+	SetHeader(rw.Request, key, value)
+	rw.userContentType(key)
+	return rw
 }
 
-func DELETE(target string, body interface{}) *http.Request {
-	return NewRequest(http.MethodDelete, target, body)
+// AddHeaderTime adds the time to the header key, formatted with DefaultTimeFormat
+func AddHeaderTime(r *http.Request, key string, t time.Time) {
+	AddHeader(r, key, formatValue(t, DefaultTimeFormat))
 }
 
-func WrapDELETE(target string, body interface{}) *RequestWrapper {
-	return Wrap(DELETE(target, body))
+func (rw *RequestWrapper) AddHeaderTime(key string, t time.Time) *RequestWrapper {
+	defer rw.trace("AddHeaderTime", key, t)()
+
+	return rw.AddHeader(key, rw.format(t))
 }
 
-// ===== body =====
+// SetHeaderTime sets the header key to the time, formatted with DefaultTimeFormat
+func SetHeaderTime(r *http.Request, key string, t time.Time) {
+	SetHeader(r, key, formatValue(t, DefaultTimeFormat))
+}
 
-// WithBody replaces the current body of the request with new body
-// Also replace the ContentLength because the body has been changed
-func WithBody(r *http.Request, body interface{}) {
-	req := NewRequest(r.Method, r.URL.String(), body)
+func (rw *RequestWrapper) SetHeaderTime(key string, t time.Time) *RequestWrapper {
+	defer rw.trace("SetHeaderTime", key, t)()
 
-	r.Body = req.Body
-	r.ContentLength = req.ContentLength
+	return rw.SetHeader(key, rw.format(t))
 }
 
-func (rw *RequestWrapper) WithBody(body interface{}) *RequestWrapper {
-	WithBody(rw.Request, body)
+// WithHeaders sets every key, value pair of headers to the header of the request.
+// It replaces any existing values associated with the keys,
+// the other keys are kept
+func WithHeaders(r *http.Request, headers map[string]string) {
+	for key, value := range headers {
+		r.Header.Set(key, value)
+	}
+}
+
+func (rw *RequestWrapper) WithHeaders(headers map[string]string) *RequestWrapper {
+	defer rw.trace("WithHeaders", redactHeaderMap(headers))()
 
+	WithHeaders(rw.Request, headers)
+	for key := range headers {
+		rw.userContentType(key)
+	}
 	return rw
 }
 
-// ===== path params =====
-// TODO: Maybe also support custom function for matching param name
-// that user can define their own
-// Example: /users/{id}, /users/{:id}, /users/_id ...
+// WithHeaderValues is the same with WithHeaders but allows multiple values for a key
+func WithHeaderValues(r *http.Request, headers http.Header) {
+	for key, values := range headers {
+		key = http.CanonicalHeaderKey(key)
 
-func WithParam(r *http.Request, param map[string]interface{}) {
-	for k, v := range param {
-		SetParam(r, k, v)
+		r.Header[key] = append([]string(nil), values...)
 	}
 }
 
-func (rw *RequestWrapper) WithParam(param map[string]interface{}) *RequestWrapper {
-	WithParam(rw.Request, param)
+func (rw *RequestWrapper) WithHeaderValues(headers http.Header) *RequestWrapper {
+	defer rw.trace("WithHeaderValues", redactHeaders(headers))()
 
+	WithHeaderValues(rw.Request, headers)
+	for key := range headers {
+		rw.userContentType(key)
+	}
 	return rw
 }
 
-func SetParam(r *http.Request, key string, value interface{}) {
-	// key should be a valid identifier, if not, panic
-	validID := regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
-	if !validID.MatchString(key) {
-		panic(fmt.Errorf("param key should be a valid identifier %v", key))
-	}
+// AddRawHeader adds the key, value pair to the header of the request without canonicalizing the key,
+// so the key is sent exactly as given, e.g. lowercase "x-request-id".
+// It writes to r.Header as a raw map, so Header.Get, Set and Del of a canonical key
+// will not see the value
+func AddRawHeader(r *http.Request, key, value string) {
+	r.Header[key] = append(r.Header[key], value)
+}
 
-	expr := `:` + key + `\b`
+func (rw *RequestWrapper) AddRawHeader(key, value string) *RequestWrapper {
+	defer rw.trace("AddRawHeader", key, redactHeader(key, value))()
 
-	re, err := regexp.Compile(expr)
-	if err != nil {
-		panic(fmt.Errorf("compile regex failed, may be key %q contain invalid regex %v", key, err))
-	}
+	AddRawHeader(rw.Request, key, value)
+	return rw
+}
 
-	r.URL.Path = re.ReplaceAllStringFunc(r.URL.Path, func(s string) string {
-		return fmt.Sprint(value)
-	})
+// SetRawHeader is the same with AddRawHeader but replaces any existing values of the exact key
+func SetRawHeader(r *http.Request, key, value string) {
+	r.Header[key] = []string{value}
 }
 
-func (rw *RequestWrapper) SetParam(key string, value interface{}) *RequestWrapper {
-	SetParam(rw.Request, key, value)
+func (rw *RequestWrapper) SetRawHeader(key, value string) *RequestWrapper {
+	defer rw.trace("SetRawHeader", key, redactHeader(key, value))()
 
+	SetRawHeader(rw.Request, key, value)
 	return rw
 }
 
-// ===== query ====
+// DeleteHeader deletes the values associated with key.
+// See: http.Header.Del
+func DeleteHeader(r *http.Request, key string) {
+	r.Header.Del(key)
+}
 
-// Add adds the value to key. It appends to any existing
-// values associated with key.
-func AddQuery(r *http.Request, key string, value interface{}) {
-	q := r.URL.Query()
+func (rw *RequestWrapper) DeleteHeader(key string) *RequestWrapper {
+	defer rw.trace("DeleteHeader", key)()
 
-	q.Add(key, fmt.Sprint(value))
-	r.URL.RawQuery = q.Encode()
+	DeleteHeader(rw.Request, key)
+	return rw
 }
 
-func (rw *RequestWrapper) AddQuery(key string, value interface{}) *RequestWrapper {
-	AddQuery(rw.Request, key, value)
+// SetContentType sets the Content-Type header of the request
+// The body builders will not override it
+func SetContentType(r *http.Request, contentType string) {
+	r.Header.Set("Content-Type", contentType)
+}
+
+func (rw *RequestWrapper) SetContentType(contentType string) *RequestWrapper {
+	defer rw.trace("SetContentType", contentType)()
 
+	SetContentType(rw.Request, contentType)
+	rw.bodyContentType = ""
 	return rw
 }
 
-// Set sets the key to value. It replaces any existing
-// values.
-func SetQuery(r *http.Request, key string, value interface{}) {
-	q := r.URL.Query()
+// WithJSONAPI sets both Accept and Content-Type headers of the request
+// to application/vnd.api+json, WithBody will not override the Content-Type
+// See: https://jsonapi.org/format/#content-negotiation
+func WithJSONAPI(r *http.Request) {
+	r.Header.Set("Accept", ContentTypeJSONAPI)
+	r.Header.Set("Content-Type", ContentTypeJSONAPI)
+}
 
-	q.Set(key, fmt.Sprint(value))
-	r.URL.RawQuery = q.Encode()
+func (rw *RequestWrapper) WithJSONAPI() *RequestWrapper {
+	defer rw.trace("WithJSONAPI")()
+
+	WithJSONAPI(rw.Request)
+	rw.bodyContentType = ""
+	return rw
 }
 
-func (rw *RequestWrapper) SetQuery(key string, value interface{}) *RequestWrapper {
-	SetQuery(rw.Request, key, value)
+// WithAccept sets the Accept header of the request to the comma-joined media types
+// Example: WithAccept(r, "application/json", "text/html;q=0.9")
+func WithAccept(r *http.Request, mediaTypes ...string) {
+	r.Header.Set("Accept", strings.Join(mediaTypes, ", "))
+}
+
+func (rw *RequestWrapper) WithAccept(mediaTypes ...string) *RequestWrapper {
+	defer rw.trace("WithAccept", mediaTypes)()
 
+	WithAccept(rw.Request, mediaTypes...)
 	return rw
 }
 
-// WithQuery replaces the current query of the request with new query
-func WithQuery(r *http.Request, query map[string][]interface{}) {
-	q := url.Values{}
-	for key, values := range query {
-		for _, value := range values {
-			q.Add(key, fmt.Sprint(value))
-		}
-	}
+// WithAcceptLanguage sets the Accept-Language header of the request to the comma-joined languages
+// Example: WithAcceptLanguage(r, "vi-VN", "en;q=0.8")
+func WithAcceptLanguage(r *http.Request, langs ...string) {
+	r.Header.Set("Accept-Language", strings.Join(langs, ", "))
+}
 
-	r.URL.RawQuery = q.Encode()
+func (rw *RequestWrapper) WithAcceptLanguage(langs ...string) *RequestWrapper {
+	defer rw.trace("WithAcceptLanguage", langs)()
+
+	WithAcceptLanguage(rw.Request, langs...)
+	return rw
 }
 
-func (rw *RequestWrapper) WithQuery(query map[string][]interface{}) *RequestWrapper {
-	WithQuery(rw.Request, query)
+// WithIfNoneMatch sets the If-None-Match header of the request
+// The etag is set as it is, so it should be quoted, example: `"33a64df5"`
+func WithIfNoneMatch(r *http.Request, etag string) {
+	r.Header.Set("If-None-Match", etag)
+}
+
+func (rw *RequestWrapper) WithIfNoneMatch(etag string) *RequestWrapper {
+	defer rw.trace("WithIfNoneMatch", etag)()
 
+	WithIfNoneMatch(rw.Request, etag)
 	return rw
 }
 
-// WithQueryString replaces the current query of the request with new query
-func WithQueryString(r *http.Request, query string) {
-	q, err := url.ParseQuery(query)
-	if err != nil {
-		panic(fmt.Errorf("parse query failed %v", err))
-	}
+// WithIfMatch sets the If-Match header of the request
+// The etag is set as it is, so it should be quoted, example: `"33a64df5"`
+func WithIfMatch(r *http.Request, etag string) {
+	r.Header.Set("If-Match", etag)
+}
 
-	r.URL.RawQuery = q.Encode()
+func (rw *RequestWrapper) WithIfMatch(etag string) *RequestWrapper {
+	defer rw.trace("WithIfMatch", etag)()
+
+	WithIfMatch(rw.Request, etag)
+	return rw
 }
 
-func (rw *RequestWrapper) WithQueryString(query string) *RequestWrapper {
-	WithQueryString(rw.Request, query)
+// WithIfModifiedSince sets the If-Modified-Since header of the request
+// The time is formatted in HTTP date format, see: http.TimeFormat
+func WithIfModifiedSince(r *http.Request, t time.Time) {
+	r.Header.Set("If-Modified-Since", t.UTC().Format(http.TimeFormat))
+}
+
+func (rw *RequestWrapper) WithIfModifiedSince(t time.Time) *RequestWrapper {
+	defer rw.trace("WithIfModifiedSince", t)()
 
+	WithIfModifiedSince(rw.Request, t)
 	return rw
 }
 
-// WithQueryValues replaces the current query of the request with new query
-func WithQueryValues(r *http.Request, query url.Values) {
-	r.URL.RawQuery = query.Encode()
+// WithRange sets the Range header of the request to bytes=start-end
+// If start is greater than end or negative, it will panic
+func WithRange(r *http.Request, start, end int64) {
+	if start < 0 || start > end {
+		panic(fmt.Errorf("invalid range %d-%d", start, end))
+	}
+
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
 }
 
-func (rw *RequestWrapper) WithQueryValues(query url.Values) *RequestWrapper {
-	WithQueryValues(rw.Request, query)
+func (rw *RequestWrapper) WithRange(start, end int64) *RequestWrapper {
+	defer rw.trace("WithRange", start, end)()
 
+	WithRange(rw.Request, start, end)
 	return rw
 }
 
-// ===== header =====
+// WithRangeFrom sets the Range header of the request to bytes=start-
+// If start is negative, it will panic
+func WithRangeFrom(r *http.Request, start int64) {
+	if start < 0 {
+		panic(fmt.Errorf("invalid range start %d", start))
+	}
 
-// AddHeader adds the key, value pair to the header of the request.
-// See: http.Header.Add
-func AddHeader(r *http.Request, key, value string) {
-	r.Header.Add(key, value)
+	r.Header.Set("Range", fmt.Sprintf("bytes=%d-", start))
 }
 
-func (rw *RequestWrapper) AddHeader(key, value string) *RequestWrapper {
-	AddHeader(rw.Request, key, value)
+func (rw *RequestWrapper) WithRangeFrom(start int64) *RequestWrapper {
+	defer rw.trace("WithRangeFrom", start)()
+
+	WithRangeFrom(rw.Request, start)
 	return rw
 }
 
-// SetHeader sets the request's header entries associated with key to the
-// single element value. It replaces any existing values
-// associated with key.
-// See: http.Header.Set
-func SetHeader(r *http.Request, key, value string) {
-	r.Header.Set(key, value)
+// WithUserAgent sets the User-Agent header of the request
+// It replaces any existing value
+func WithUserAgent(r *http.Request, ua string) {
+	r.Header.Set("User-Agent", ua)
 }
 
-func (rw *RequestWrapper) SetHeader(key, value string) *RequestWrapper {
-	SetHeader(rw.Request, key, value)
+func (rw *RequestWrapper) WithUserAgent(ua string) *RequestWrapper {
+	defer rw.trace("WithUserAgent", ua)()
+
+	WithUserAgent(rw.Request, ua)
+	return rw
+}
+
+// MethodOverrideHeader is the header set by WithMethodOverride
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// WithMethodOverride sets the X-HTTP-Method-Override header to the method,
+// the actual method of the request is kept, usually POST
+func WithMethodOverride(r *http.Request, method string) {
+	r.Header.Set(MethodOverrideHeader, method)
+}
+
+func (rw *RequestWrapper) WithMethodOverride(method string) *RequestWrapper {
+	defer rw.trace("WithMethodOverride", method)()
+
+	WithMethodOverride(rw.Request, method)
 	return rw
 }
 
@@ -271,6 +2895,8 @@ func (rw *RequestWrapper) SetHeader(key, value string) *RequestWrapper {
 // Basic Authentication with the provided username and password.
 // See: http.Request.SetBasicAuth
 func (rw *RequestWrapper) SetBasicAuth(username, password string) *RequestWrapper {
+	defer rw.trace("SetBasicAuth", username, redacted)()
+
 	rw.Request.SetBasicAuth(username, password)
 
 	return rw
@@ -283,13 +2909,103 @@ func SetBearerAuth(r *http.Request, token string) {
 }
 
 func (rw *RequestWrapper) SetBearerAuth(token string) *RequestWrapper {
+	defer rw.trace("SetBearerAuth", redacted)()
+
 	SetBearerAuth(rw.Request, token)
 
 	return rw
 }
 
+// DefaultAPIKeyHeader is the header used by SetAPIKeyDefault
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// SetAPIKey sets the API key to the given header of the request
+func SetAPIKey(r *http.Request, header, key string) {
+	r.Header.Set(header, key)
+}
+
+func (rw *RequestWrapper) SetAPIKey(header, key string) *RequestWrapper {
+	defer rw.trace("SetAPIKey", header, redacted)()
+
+	SetAPIKey(rw.Request, header, key)
+
+	return rw
+}
+
+// SetAPIKeyDefault sets the API key to the X-API-Key header of the request
+func SetAPIKeyDefault(r *http.Request, key string) {
+	SetAPIKey(r, DefaultAPIKeyHeader, key)
+}
+
+func (rw *RequestWrapper) SetAPIKeyDefault(key string) *RequestWrapper {
+	defer rw.trace("SetAPIKeyDefault", redacted)()
+
+	SetAPIKeyDefault(rw.Request, key)
+
+	return rw
+}
+
 func (rw *RequestWrapper) AddCookie(c *http.Cookie) *RequestWrapper {
+	defer rw.trace("AddCookie", redactCookies(c)[0])()
+
 	rw.Request.AddCookie(c)
 
 	return rw
 }
+
+// AddCookies adds the cookies to the request
+// See: http.Request.AddCookie
+func AddCookies(r *http.Request, cookies ...*http.Cookie) {
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+}
+
+func (rw *RequestWrapper) AddCookies(cookies ...*http.Cookie) *RequestWrapper {
+	defer rw.trace("AddCookies", redactCookies(cookies...))()
+
+	AddCookies(rw.Request, cookies...)
+
+	return rw
+}
+
+// SetCookie adds the cookie to the request,
+// it replaces any existing cookie with the same name
+func SetCookie(r *http.Request, c *http.Cookie) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+
+	for _, existing := range cookies {
+		if existing.Name != c.Name {
+			r.AddCookie(existing)
+		}
+	}
+
+	r.AddCookie(c)
+}
+
+func (rw *RequestWrapper) SetCookie(c *http.Cookie) *RequestWrapper {
+	defer rw.trace("SetCookie", redactCookies(c)[0])()
+
+	SetCookie(rw.Request, c)
+
+	return rw
+}
+
+// WithCookieJar adds the cookies of the jar for the request URL to the request
+// The request URL must be absolute, for example by using WithBaseURL, if not, it will panic
+func WithCookieJar(r *http.Request, jar http.CookieJar) {
+	if !r.URL.IsAbs() || r.URL.Host == "" {
+		panic(fmt.Errorf("request URL should be absolute to get cookies from jar %q", r.URL))
+	}
+
+	AddCookies(r, jar.Cookies(r.URL)...)
+}
+
+func (rw *RequestWrapper) WithCookieJar(jar http.CookieJar) *RequestWrapper {
+	defer rw.trace("WithCookieJar", jar)()
+
+	WithCookieJar(rw.Request, jar)
+
+	return rw
+}
@@ -2,15 +2,22 @@ package jat_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/victornm/jat"
+	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"net/http/cookiejar"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestMethod(t *testing.T) {
@@ -64,6 +71,33 @@ func TestMethod(t *testing.T) {
 			wantedMethod: http.MethodDelete,
 			wantedURI:    "/users",
 		},
+
+		{
+			f: func() *http.Request {
+				return jat.WrapMethod("PROPFIND", "/users", nil).Unwrap()
+			},
+
+			wantedMethod: "PROPFIND",
+			wantedURI:    "/users",
+		},
+
+		{
+			f: func() *http.Request {
+				return jat.WrapHEAD("/users").Unwrap()
+			},
+
+			wantedMethod: http.MethodHead,
+			wantedURI:    "/users",
+		},
+
+		{
+			f: func() *http.Request {
+				return jat.WrapOPTIONS("/users", nil).Unwrap()
+			},
+
+			wantedMethod: http.MethodOptions,
+			wantedURI:    "/users",
+		},
 	}
 
 	for _, test := range tests {
@@ -77,6 +111,94 @@ func TestMethod(t *testing.T) {
 	}
 }
 
+func TestInvalidMethod(t *testing.T) {
+	for _, method := range []string{"", "GET POST", "GET\n", "MKCOL()"} {
+		t.Run(method, func(t *testing.T) {
+			assert.Panics(t, func() {
+				jat.Method(method, "/users", nil)
+			})
+		})
+	}
+}
+
+func TestWithoutBody(t *testing.T) {
+	for _, req := range []*http.Request{jat.GET("/users"), jat.HEAD("/users")} {
+		t.Run(req.Method, func(t *testing.T) {
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Empty(t, b)
+			assert.Equal(t, int64(0), req.ContentLength)
+		})
+	}
+}
+
+func TestStrictBody(t *testing.T) {
+	t.Run("get without body", func(t *testing.T) {
+		_, err := jat.WrapGET("/users").WithStrictBody().TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("get from builder", func(t *testing.T) {
+		_, err := jat.NewBuilder().
+			BaseURL("http://localhost:3000").
+			Build(http.MethodGet, "/users").
+			WithStrictBody().
+			TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("get from url", func(t *testing.T) {
+		_, err := jat.WrapURL(http.MethodGet, &url.URL{Path: "/users"}, nil).WithStrictBody().TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("disabled by default", func(t *testing.T) {
+		_, err := jat.WrapGET("/users").WithBodyString("hello").TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("get with body", func(t *testing.T) {
+		rw := jat.WrapGET("/users").WithStrictBody().WithBodyString("hello")
+
+		_, err := rw.TryUnwrap()
+		assert.Error(t, err)
+		assert.Panics(t, func() { rw.Unwrap() })
+	})
+
+	t.Run("head with body", func(t *testing.T) {
+		_, err := jat.WrapHEAD("/users").
+			WithStrictBody().
+			WithBody(map[string]string{"email": "foo@bar.com"}).
+			TryUnwrap()
+
+		assert.Error(t, err)
+	})
+
+	t.Run("allow body", func(t *testing.T) {
+		_, err := jat.WrapGET("/users").
+			WithStrictBody().
+			WithBodyString("hello").
+			AllowBody().
+			TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("post with body", func(t *testing.T) {
+		_, err := jat.WrapPOST("/users", nil).
+			WithStrictBody().
+			WithBodyString("hello").
+			TryUnwrap()
+
+		assert.NoError(t, err)
+	})
+}
+
 func TestBodyJSON(t *testing.T) {
 	tests := map[string]struct {
 		f func(wrapper *jat.RequestWrapper)
@@ -147,278 +269,2640 @@ func TestBodyJSON(t *testing.T) {
 	}
 }
 
-func TestHeader(t *testing.T) {
-	target := "/api/ping"
+func TestTry(t *testing.T) {
+	t.Run("new request", func(t *testing.T) {
+		req, err := jat.TryNewRequest(http.MethodPost, "/users", map[string]string{"email": "foo@bar.com"})
 
-	t.Run("add header", func(t *testing.T) {
-		req := jat.WrapGET(target).
-			AddHeader("Host", "localhost:3000").
-			Unwrap()
+		assert.NoError(t, err)
+		assert.Equal(t, "/users", req.URL.Path)
+	})
 
-		wanted := http.Header{
-			"Host": []string{"localhost:3000"},
-		}
+	t.Run("new request with invalid body", func(t *testing.T) {
+		_, err := jat.TryNewRequest(http.MethodPost, "/users", make(chan int))
 
-		if !reflect.DeepEqual(req.Header, wanted) {
-			t.Error("unexpected header")
-		}
+		assert.Error(t, err)
 	})
 
-	t.Run("add header twice", func(t *testing.T) {
-		req := jat.WrapGET(target).
-			AddHeader("Host", "localhost:3000").
-			AddHeader("Host", "localhost:8080").
-			Unwrap()
+	t.Run("new request with invalid target", func(t *testing.T) {
+		_, err := jat.TryNewRequest(http.MethodGet, "/users\n", nil)
 
-		wanted := http.Header{
-			"Host": []string{"localhost:3000", "localhost:8080"},
-		}
+		assert.EqualError(t, err, `invalid target "/users\n" of GET request: net/url: invalid control character in URL`)
 
-		if !reflect.DeepEqual(req.Header, wanted) {
-			t.Error("unexpected header")
-		}
-	})
+		_, err = jat.TryNewRequest(http.MethodPost, "users", nil)
 
-	t.Run("set header twice", func(t *testing.T) {
-		req := jat.WrapGET(target).
-			AddHeader("Host", "localhost:3000").
-			AddHeader("Host", "localhost:8080").
-			SetHeader("Host", "localhost:5432").
-			Unwrap()
+		assert.EqualError(t, err, `invalid target "users" of POST request: invalid URI for request`)
 
-		wanted := http.Header{
-			"Host": []string{"localhost:5432"},
-		}
+		_, err = jat.TryNewRequest(http.MethodGet, "/users list", nil)
 
-		if !reflect.DeepEqual(req.Header, wanted) {
-			t.Error("unexpected header")
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `invalid target "/users list" of GET request: `)
 		}
-	})
 
-	t.Run("set basic auth", func(t *testing.T) {
-		username, password := "foo", "bar"
-		req := jat.WrapGET(target).
-			SetBasicAuth(username, password).
-			Unwrap()
+		assert.PanicsWithError(t, `invalid target "users" of DELETE request: invalid URI for request`, func() {
+			jat.DELETE("users", nil)
+		})
+	})
 
-		gotU, gotP, ok := req.BasicAuth()
+	t.Run("unwrap with invalid body", func(t *testing.T) {
+		rw := jat.WrapPOST("/users", nil).WithBody(make(chan int))
 
-		assert.True(t, ok)
-		assert.Equal(t, username, gotU)
-		assert.Equal(t, password, gotP)
+		_, err := rw.TryUnwrap()
+		assert.Error(t, err)
+		assert.Panics(t, func() { rw.Unwrap() })
 	})
 
-	t.Run("add bearer auth", func(t *testing.T) {
-		token := "6eTUFP4HNhvvIwz5nNiL"
-		req := jat.WrapGET(target).
-			SetBearerAuth(token).
-			Unwrap()
-
-		gotToken := req.Header.Get("Authorization")
+	t.Run("unwrap with invalid query", func(t *testing.T) {
+		_, err := jat.WrapGET("/users").
+			WithQueryString("type=%zz").
+			TryUnwrap()
 
-		assert.Equal(t, "Bearer "+token, gotToken)
+		assert.Error(t, err)
 	})
 
-	t.Run("add cookie", func(t *testing.T) {
-		username, password := "foo", "bar"
-		req := jat.WrapGET(target).
-			AddCookie(&http.Cookie{Name: username, Value: password}).
-			Unwrap()
+	t.Run("unwrap with invalid added query", func(t *testing.T) {
+		_, err := jat.WrapGET("/users").
+			AddQueryString("type=%zz").
+			TryUnwrap()
 
-		gotC, err := req.Cookie(username)
+		assert.Error(t, err)
+	})
+
+	t.Run("unwrap without error", func(t *testing.T) {
+		req, err := jat.WrapGET("/users").
+			WithQueryString("type=code").
+			TryUnwrap()
 
 		assert.NoError(t, err)
-		assert.Equal(t, password, gotC.Value)
+		assert.Equal(t, "/users?type=code", req.URL.RequestURI())
 	})
 }
 
-func TestQuery(t *testing.T) {
-	tests := map[string]struct {
-		initURI string
+func TestClone(t *testing.T) {
+	parent := jat.WrapPOST("/users", map[string]string{"email": "foo@bar.com"}).
+		SetHeader("Authorization", "Bearer token").
+		AddQuery("type", "code")
 
-		f func(wrapper *jat.RequestWrapper)
+	clone := parent.Clone().
+		SetHeader("Authorization", "Bearer other").
+		SetQuery("type", "token")
 
-		wanted string // Note: query should be sorted by key
-	}{
-		"add query": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.AddQuery("type", "code")
-			},
+	parentReq := parent.Unwrap()
+	cloneReq := clone.Unwrap()
 
-			wanted: "/api/ping?type=code",
-		},
+	assert.Equal(t, "Bearer token", parentReq.Header.Get("Authorization"))
+	assert.Equal(t, "Bearer other", cloneReq.Header.Get("Authorization"))
 
-		"add query integer": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.AddQuery("num", 1)
-			},
+	assert.Equal(t, "/users?type=code", parentReq.URL.RequestURI())
+	assert.Equal(t, "/users?type=token", cloneReq.URL.RequestURI())
 
-			wanted: "/api/ping?num=1",
-		},
+	parentBody, err := ioutil.ReadAll(parentReq.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(parentBody))
 
-		"add two queries": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.
-					AddQuery("type", "code").
-					AddQuery("provider", "google")
-			},
+	cloneBody, err := ioutil.ReadAll(cloneReq.Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(cloneBody))
+}
 
-			wanted: "/api/ping?provider=google&type=code",
-		},
+func TestReset(t *testing.T) {
+	rw := jat.WrapPOST("/users", nil).
+		WithBody(make(chan int)).
+		WithStrictParams().
+		SetBearerAuth("token").
+		AddQuery("type", "code").
+		WithTimeout(time.Minute)
+	ctx := rw.Request.Context()
+
+	req, err := rw.Reset(http.MethodGet, "/users/:id").TryUnwrap()
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "/users/:id", req.URL.String())
+	assert.Empty(t, req.Header)
+	assert.Equal(t, int64(0), req.ContentLength)
+	assert.Error(t, ctx.Err())
+}
 
-		"add query twice": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.
-					AddQuery("type", "code").
-					AddQuery("type", "token")
-			},
+func TestUse(t *testing.T) {
+	sign := func(r *http.Request) {
+		r.Header.Set("X-Signature", r.Method+" "+r.URL.Path)
+	}
 
-			wanted: "/api/ping?type=code&type=token",
-		},
+	req := jat.WrapPOST("/orders/:id", nil).
+		SetParam("id", 1).
+		Use(sign).
+		AddQuery("type", "code").
+		Unwrap()
 
-		"set query": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.
-					AddQuery("type", "code").
-					AddQuery("provider", "google").
-					SetQuery("type", "money")
-			},
+	assert.Equal(t, "POST /orders/1", req.Header.Get("X-Signature"))
+	assert.Equal(t, "/orders/1?type=code", req.URL.String())
+}
 
-			wanted: "/api/ping?provider=google&type=money",
-		},
+func TestWhen(t *testing.T) {
+	auth := func(rw *jat.RequestWrapper) *jat.RequestWrapper {
+		return rw.SetBearerAuth("token")
+	}
 
-		"query from string": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.WithQueryString("provider=google&type=money")
-			},
+	req := jat.WrapGET("/users").When(true, auth).AddQuery("type", "code").Unwrap()
 
-			wanted: "/api/ping?provider=google&type=money",
-		},
+	assert.Equal(t, "Bearer token", req.Header.Get("Authorization"))
+	assert.Equal(t, "/users?type=code", req.URL.String())
 
-		"query from map": {
-			initURI: "/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.WithQuery(map[string][]interface{}{
-					"provider": {"google"},
-					"int":      {1},
-					"float":    {12.34},
-					"negative": {-5},
-				})
-			},
+	req = jat.WrapGET("/users").When(false, auth).Unwrap()
 
-			wanted: "/api/ping?float=12.34&int=1&negative=-5&provider=google",
-		},
+	assert.Empty(t, req.Header.Get("Authorization"))
 
-		"query from url.Values": {
-			initURI: "localhost:3000/api/ping",
-			f: func(wrapper *jat.RequestWrapper) {
-				wrapper.WithQueryValues(url.Values{
-					"provider": {"google"},
-					"type":     {"money"},
-				})
-			},
+	trace := jat.WrapGET("/users").EnableTrace().When(true, auth).Trace()
 
-			wanted: "localhost:3000/api/ping?provider=google&type=money",
-		},
+	assert.Equal(t, []string{`SetBearerAuth("***")`}, trace)
+}
+
+func TestForEach(t *testing.T) {
+	addID := func(rw *jat.RequestWrapper, id interface{}) {
+		rw.AddQuery("id", id)
 	}
 
-	for name, test := range tests {
-		t.Run(name, func(t *testing.T) {
-			wrapper := jat.WrapGET(test.initURI)
-			test.f(wrapper)
+	req := jat.WrapGET("/users").ForEach([]int{1, 2, 3}, addID).Unwrap()
 
-			req := wrapper.Unwrap()
+	assert.Equal(t, "/users?id=1&id=2&id=3", req.URL.String())
 
-			got := req.URL.String()
-			if test.wanted != got {
-				t.Errorf("unexpected query: wanted %q but got %q", test.wanted, got)
-			}
-		})
-	}
-}
+	req = jat.WrapGET("/users").ForEach([0]int{}, addID).Unwrap()
 
-func TestParam(t *testing.T) {
-	tests := map[string]struct {
-		template string
-		param    map[string]interface{}
+	assert.Equal(t, "/users", req.URL.String())
 
-		wantedPath string
-	}{
-		"int param": {
-			template: "/users/:id",
-			param: map[string]interface{}{
-				"id": 1,
-			},
+	_, err := jat.WrapGET("/users").ForEach(1, addID).TryUnwrap()
 
-			wantedPath: "/users/1",
-		},
+	assert.EqualError(t, err, "values of ForEach should be a slice or an array, got int")
+}
 
-		"string param": {
-			template: "/users/:id/courses/:course_name",
-			param: map[string]interface{}{
-				"id":          1,
-				"course_name": "cs50",
-			},
+func TestTrace(t *testing.T) {
+	t.Run("record mutations", func(t *testing.T) {
+		rw := jat.WrapPOST("/users/:id", nil).
+			SetHeader("X-Before", "1").
+			EnableTrace().
+			SetParam("id", 1).
+			AddQueryInt("page", 2).
+			SetHeader("X-Request-Id", "abc").
+			WithBody(map[string]string{"email": "foo@bar.com"})
+
+		assert.Equal(t, []string{
+			`SetParam("id", 1)`,
+			`AddQueryInt("page", 2)`,
+			`SetHeader("X-Request-Id", "abc")`,
+			`WithBody(map[email:foo@bar.com])`,
+		}, rw.Trace())
+	})
 
-			wantedPath: "/users/1/courses/cs50",
-		},
+	t.Run("redact secrets", func(t *testing.T) {
+		rw := jat.WrapGET("/users").
+			EnableTrace().
+			SetBasicAuth("foo", "password").
+			SetBearerAuth("token").
+			SetAPIKey("X-Key", "key").
+			SetAPIKeyDefault("key").
+			SetDigestAuth("foo", "password", jat.DigestChallenge{Nonce: "nonce"})
+
+		assert.Equal(t, []string{
+			`SetBasicAuth("foo", "***")`,
+			`SetBearerAuth("***")`,
+			`SetAPIKey("X-Key", "***")`,
+			`SetAPIKeyDefault("***")`,
+			`SetDigestAuth("foo", "***", { nonce   })`,
+		}, rw.Trace())
+
+		rw = jat.WrapGET("/users").
+			EnableTrace().
+			SetHeader("Authorization", "Bearer secret").
+			AddHeader("proxy-authorization", "Basic secret").
+			SetRawHeader("cookie", "session=secret").
+			AddRawHeader("X-API-Key", "key").
+			AddHeader("X-Request-Id", "1").
+			WithHeaders(map[string]string{"Authorization": "secret", "Accept": "text/plain"}).
+			WithHeaderValues(http.Header{"Cookie": {"a=1", "b=2"}}).
+			AddCookie(&http.Cookie{Name: "session", Value: "secret"}).
+			AddCookies(&http.Cookie{Name: "a", Value: "1"}, &http.Cookie{Name: "b", Value: "2"}).
+			SetCookie(&http.Cookie{Name: "session", Value: "other"})
+
+		assert.Equal(t, []string{
+			`SetHeader("Authorization", "***")`,
+			`AddHeader("proxy-authorization", "***")`,
+			`SetRawHeader("cookie", "***")`,
+			`AddRawHeader("X-API-Key", "***")`,
+			`AddHeader("X-Request-Id", "1")`,
+			`WithHeaders(map[Accept:text/plain Authorization:***])`,
+			`WithHeaderValues(map[Cookie:[*** ***]])`,
+			`AddCookie("session=***")`,
+			`AddCookies([a=*** b=***])`,
+			`SetCookie("session=***")`,
+		}, rw.Trace())
+	})
 
-		"more param than template": {
-			template: "/users/:id",
-			param: map[string]interface{}{
-				"id":          1,
-				"course_name": "cs50",
-			},
+	t.Run("disabled by default", func(t *testing.T) {
+		rw := jat.WrapGET("/users").AddQuery("type", "code")
 
-			wantedPath: "/users/1",
+		assert.Empty(t, rw.Trace())
+	})
+}
+
+func TestContext(t *testing.T) {
+	type key string
+	ctx := context.WithValue(context.Background(), key("user"), "foo")
+
+	req := jat.WrapGET("/users").
+		WithContext(ctx).
+		AddQuery("type", "code").
+		Unwrap()
+
+	assert.Equal(t, "foo", req.Context().Value(key("user")))
+	assert.Equal(t, "/users?type=code", req.URL.RequestURI())
+}
+
+func TestTimeout(t *testing.T) {
+	rw := jat.WrapGET("/users").WithTimeout(time.Minute)
+	defer rw.Cancel()
+
+	req := rw.Unwrap()
+
+	_, ok := req.Context().Deadline()
+	assert.True(t, ok)
+	assert.NoError(t, req.Context().Err())
+
+	rw.Cancel()
+	assert.Equal(t, context.Canceled, req.Context().Err())
+}
+
+func TestCloneTimeout(t *testing.T) {
+	rw := jat.WrapGET("/users").WithTimeout(time.Minute)
+	defer rw.Cancel()
+
+	clone := rw.Clone()
+	clone.Cancel()
+
+	_, ok := clone.Unwrap().Context().Deadline()
+	assert.True(t, ok)
+	assert.Equal(t, context.Canceled, clone.Unwrap().Context().Err())
+	assert.NoError(t, rw.Unwrap().Context().Err())
+
+	other := rw.Clone()
+	rw.Cancel()
+
+	assert.Equal(t, context.Canceled, other.Unwrap().Context().Err())
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("package logger", func(t *testing.T) {
+		var logs []string
+		jat.SetLogger(func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		})
+		defer jat.SetLogger(log.Printf)
+
+		jat.WrapGET("/users").Unwrap()
+
+		assert.Equal(t, []string{"[GET] /users\n"}, logs)
+	})
+
+	t.Run("wrapper logger", func(t *testing.T) {
+		var logs []string
+		jat.WrapGET("/users").
+			WithLogger(func(format string, args ...interface{}) {
+				logs = append(logs, fmt.Sprintf(format, args...))
+			}).
+			Unwrap()
+
+		assert.Equal(t, []string{"[GET] /users\n"}, logs)
+	})
+
+	t.Run("disable logger", func(t *testing.T) {
+		var logs []string
+		jat.SetLogger(func(format string, args ...interface{}) {
+			logs = append(logs, fmt.Sprintf(format, args...))
+		})
+		defer jat.SetLogger(log.Printf)
+
+		jat.WrapGET("/users").WithLogger(nil).Unwrap()
+
+		assert.Empty(t, logs)
+	})
+}
+
+func TestGetBody(t *testing.T) {
+	tests := map[string]struct {
+		f func() *http.Request
+
+		wanted string
+	}{
+		"new request": {
+			f: func() *http.Request {
+				return jat.POST("/", map[string]string{"email": "foo@bar.com"})
+			},
+
+			wanted: `{"email":"foo@bar.com"}`,
+		},
+
+		"body from io.Reader": {
+			f: func() *http.Request {
+				return jat.WrapPOST("/", nil).
+					WithBody(ioutil.NopCloser(strings.NewReader("hello"))).
+					Unwrap()
+			},
+
+			wanted: "hello",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := test.f()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+
+			for i := 0; i < 2; i++ {
+				rc, err := req.GetBody()
+				assert.NoError(t, err)
+
+				b, err = ioutil.ReadAll(rc)
+				assert.NoError(t, err)
+				assert.Equal(t, test.wanted, string(b))
+			}
+		})
+	}
+}
+
+func TestBodyFunc(t *testing.T) {
+	calls := 0
+	fn := func() io.Reader {
+		calls++
+		return strings.NewReader("generated")
+	}
+
+	req := jat.WrapPOST("/upload", nil).
+		WithBody(fn).
+		Unwrap()
+
+	assert.Equal(t, 0, calls)
+	assert.Equal(t, int64(-1), req.ContentLength)
+
+	assert.Equal(t, "generated", string(jat.RawBody(req)))
+	assert.Equal(t, 1, calls)
+
+	body, err := req.GetBody()
+	if assert.NoError(t, err) {
+		b, _ := ioutil.ReadAll(body)
+		assert.Equal(t, "generated", string(b))
+		assert.Equal(t, 2, calls)
+	}
+}
+
+type closeSpy struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeSpy) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestBodyClose(t *testing.T) {
+	t.Run("new request", func(t *testing.T) {
+		spy := &closeSpy{Reader: strings.NewReader("hello")}
+		req := jat.POST("/upload", spy)
+
+		assert.Equal(t, "hello", string(jat.RawBody(req)))
+		assert.False(t, spy.closed)
+
+		assert.NoError(t, req.Body.Close())
+		assert.True(t, spy.closed)
+	})
+
+	t.Run("with body and dump", func(t *testing.T) {
+		spy := &closeSpy{Reader: strings.NewReader("hello")}
+		rw := jat.WrapPOST("/upload", nil).WithBody(spy)
+
+		assert.Contains(t, rw.Dump(), "hello")
+		assert.False(t, spy.closed)
+
+		assert.NoError(t, rw.Unwrap().Body.Close())
+		assert.True(t, spy.closed)
+	})
+}
+
+func TestContentLength(t *testing.T) {
+	req := jat.WrapPOST("/", nil).
+		WithBodyString("hello").
+		SetContentLength(100).
+		Unwrap()
+
+	b, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "hello", string(b))
+	assert.Equal(t, int64(100), req.ContentLength)
+}
+
+func TestChunked(t *testing.T) {
+	req := jat.WrapPOST("/", nil).
+		WithBodyString("hello").
+		WithChunked().
+		Unwrap()
+
+	b, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "hello", string(b))
+	assert.Equal(t, int64(-1), req.ContentLength)
+	assert.Equal(t, []string{"chunked"}, req.TransferEncoding)
+}
+
+func TestTrailer(t *testing.T) {
+	req := jat.WrapPOST("/", nil).
+		WithBodyString("hello").
+		WithChunked().
+		WithTrailer("grpc-status", "0").
+		WithTrailer("Grpc-Message", "OK").
+		WithTrailer("grpc-status", "1").
+		Unwrap()
+
+	assert.Equal(t, []string{"Grpc-Status", "Grpc-Message"}, req.Header["Trailer"])
+	assert.Equal(t, http.Header{
+		"Grpc-Status":  {"1"},
+		"Grpc-Message": {"OK"},
+	}, req.Trailer)
+}
+
+func TestRawBody(t *testing.T) {
+	rw := jat.WrapPOST("/", map[string]string{"email": "foo@bar.com"})
+
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(rw.RawBody()))
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(rw.RawBody()))
+
+	b, err := ioutil.ReadAll(rw.Unwrap().Body)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(b))
+}
+
+func TestBodyString(t *testing.T) {
+	body := `{"email": "foo@bar.com"`
+
+	req := jat.WrapPOST("/", nil).
+		WithBodyString(body).
+		Unwrap()
+
+	b, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, body, string(b))
+	assert.Equal(t, int64(len(body)), req.ContentLength)
+}
+
+func TestBodyBytes(t *testing.T) {
+	body := []byte{0x08, 0x96, 0x01}
+
+	req := jat.WrapPOST("/", nil).
+		WithBodyBytes(body).
+		Unwrap()
+
+	b, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, body, b)
+	assert.Equal(t, int64(len(body)), req.ContentLength)
+
+	rc, err := req.GetBody()
+	assert.NoError(t, err)
+
+	b, err = ioutil.ReadAll(rc)
+	assert.NoError(t, err)
+	assert.Equal(t, body, b)
+}
+
+func TestJSONOptions(t *testing.T) {
+	body := map[string]string{"html": "<b>&</b>"}
+
+	tests := map[string]struct {
+		f func(wrapper *jat.RequestWrapper)
+
+		wanted string
+	}{
+		"default": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithBody(body)
+			},
+
+			wanted: `{"html":"\u003cb\u003e\u0026\u003c/b\u003e"}`,
+		},
+
+		"disable html escape": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					WithJSONOptions(jat.JSONOptions{DisableHTMLEscape: true}).
+					WithBody(body)
+			},
+
+			wanted: `{"html":"<b>&</b>"}`,
+		},
+
+		"indent": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					WithJSONOptions(jat.JSONOptions{Indent: "  "}).
+					WithBody(body)
+			},
+
+			wanted: "{\n  \"html\": \"\\u003cb\\u003e\\u0026\\u003c/b\\u003e\"\n}",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wrapper := jat.WrapPOST("/", nil)
+			test.f(wrapper)
+
+			req := wrapper.Unwrap()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+		})
+	}
+}
+
+func TestBodyExcept(t *testing.T) {
+	type user struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+		Age      int64  `json:"age"`
+	}
+
+	t.Run("omit fields", func(t *testing.T) {
+		req := jat.WrapPOST("/users", nil).
+			WithBodyExcept(user{Email: "foo@bar.com", Password: "secret", Age: 9007199254740993}, "password", "missing").
+			Unwrap()
+
+		assert.Equal(t, `{"age":9007199254740993,"email":"foo@bar.com"}`, string(jat.RawBody(req)))
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("not an object", func(t *testing.T) {
+		_, err := jat.WrapPOST("/users", nil).
+			WithBodyExcept([]string{"a"}, "a").
+			TryUnwrap()
+
+		assert.Error(t, err)
+
+		assert.Panics(t, func() {
+			jat.WithBodyExcept(jat.POST("/users", nil), nil)
+		})
+	})
+}
+
+func TestBodyMerge(t *testing.T) {
+	type address struct {
+		City    string `json:"city"`
+		Country string `json:"country"`
+	}
+
+	type user struct {
+		Email   string  `json:"email"`
+		Address address `json:"address"`
+	}
+
+	base := user{Email: "foo@bar.com", Address: address{City: "Hanoi", Country: "VN"}}
+
+	t.Run("override fields", func(t *testing.T) {
+		req := jat.WrapPOST("/users", nil).
+			WithBodyMerge(base, map[string]interface{}{
+				"email":        nil,
+				"address.city": "Saigon",
+				"meta.tags":    []string{"vip"},
+			}).
+			Unwrap()
+
+		assert.JSONEq(t, `{
+			"email": null,
+			"address": {"city": "Saigon", "country": "VN"},
+			"meta": {"tags": ["vip"]}
+		}`, string(jat.RawBody(req)))
+	})
+
+	t.Run("path through non object", func(t *testing.T) {
+		_, err := jat.WrapPOST("/users", nil).
+			WithBodyMerge(base, map[string]interface{}{"email.domain": "bar.com"}).
+			TryUnwrap()
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), `"email" is not an object`)
+		}
+	})
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	type item struct {
+		Name  string                 `json:"name"`
+		Attrs map[string]interface{} `json:"attrs"`
+		ID    int64                  `json:"id"`
+	}
+
+	tests := map[string]struct {
+		body interface{}
+
+		wanted string
+	}{
+		"body from struct": {
+			body: item{Name: "foo", Attrs: map[string]interface{}{"z": 1, "a": []int{2}}, ID: 9007199254740993},
+
+			wanted: `{"attrs":{"a":[2],"z":1},"id":9007199254740993,"name":"foo"}`,
+		},
+
+		"body from io.Reader": {
+			body: strings.NewReader(`{"b": {"d": 1, "c": [true, null]}, "a": "x"}`),
+
+			wanted: `{"a":"x","b":{"c":[true,null],"d":1}}`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/", nil).
+				WithCanonicalJSON(test.body).
+				Unwrap()
+
+			assert.Equal(t, test.wanted, string(jat.RawBody(req)))
+			assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithCanonicalJSON(jat.POST("/", nil), strings.NewReader("{"))
+		})
+	})
+}
+
+func TestJSONFile(t *testing.T) {
+	t.Run("valid file", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithJSONFile("testdata/user.json").
+			Unwrap()
+
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		assert.JSONEq(t, `{"email": "foo@bar.com"}`, string(b))
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		assert.PanicsWithError(t, `invalid JSON file "testdata/invalid.json"`, func() {
+			jat.WrapPOST("/", nil).WithJSONFile("testdata/invalid.json")
+		})
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WrapPOST("/", nil).WithJSONFile("testdata/missing.json")
+		})
+	})
+}
+
+func TestBodyContentType(t *testing.T) {
+	t.Run("body from struct", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithBody(map[string]string{"email": "foo@bar.com"}).
+			Unwrap()
+
+		assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("body from constructor", func(t *testing.T) {
+		req := jat.WrapPOST("/", map[string]string{"email": "foo@bar.com"}).Unwrap()
+
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+
+		req = jat.WrapPUT("/", strings.NewReader("hello")).Unwrap()
+
+		assert.Empty(t, req.Header.Get("Content-Type"))
+
+		req = jat.WrapPOST("/", nil).WithForm(map[string]interface{}{"name": "foo"}).Unwrap()
+
+		assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("body from io.Reader", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithBody(strings.NewReader("hello")).
+			Unwrap()
+
+		assert.Empty(t, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("content type set explicitly", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			SetHeader("Content-Type", "application/merge-patch+json").
+			WithBody(map[string]string{"email": "foo@bar.com"}).
+			Unwrap()
+
+		assert.Equal(t, "application/merge-patch+json", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("json api", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithJSONAPI().
+			WithBody(map[string]interface{}{"data": map[string]string{"type": "users"}}).
+			Unwrap()
+
+		assert.Equal(t, jat.ContentTypeJSONAPI, req.Header.Get("Content-Type"))
+		assert.Equal(t, jat.ContentTypeJSONAPI, req.Header.Get("Accept"))
+	})
+
+	t.Run("set content type before form", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			SetContentType("application/x-custom-form").
+			WithForm(map[string]interface{}{"email": "foo@bar.com"}).
+			Unwrap()
+
+		assert.Equal(t, "application/x-custom-form", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("set content type after body", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithBody(map[string]string{"email": "foo@bar.com"}).
+			SetContentType(jat.ContentTypeForm).
+			Unwrap()
+
+		assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("replace json with form", func(t *testing.T) {
+		req := jat.WrapPOST("/", map[string]int{"a": 1}).
+			WithForm(map[string]interface{}{"b": 2}).
+			Unwrap()
+
+		assert.Equal(t, "b=2", string(jat.RawBody(req)))
+		assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("replace json with xml", func(t *testing.T) {
+		type user struct {
+			Name string `xml:"name"`
+		}
+
+		base := jat.WrapPOST("/", nil).WithBody(map[string]int{"a": 1})
+		req := base.Clone().WithXML(user{Name: "foo"}).Unwrap()
+
+		assert.Equal(t, "<user><name>foo</name></user>", string(jat.RawBody(req)))
+		assert.Equal(t, jat.ContentTypeXML, req.Header.Get("Content-Type"))
+		assert.Equal(t, jat.ContentTypeJSON, base.Unwrap().Header.Get("Content-Type"))
+	})
+
+	t.Run("replace body with io.Reader", func(t *testing.T) {
+		req := jat.WrapPOST("/", map[string]int{"a": 1}).
+			WithBody(strings.NewReader(`{"b":2}`)).
+			Unwrap()
+
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("replace body after content type set explicitly", func(t *testing.T) {
+		type user struct {
+			Name string `xml:"name"`
+		}
+
+		req := jat.WrapPOST("/", nil).
+			WithBody(map[string]int{"a": 1}).
+			SetContentType(jat.ContentTypeJSON).
+			WithForm(map[string]interface{}{"b": 2}).
+			Unwrap()
+
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+
+		req = jat.WrapPOST("/", map[string]int{"a": 1}).
+			SetHeader("Content-Type", "application/merge-patch+json").
+			WithXML(user{Name: "foo"}).
+			Unwrap()
+
+		assert.Equal(t, "application/merge-patch+json", req.Header.Get("Content-Type"))
+	})
+
+	t.Run("replace body with charset", func(t *testing.T) {
+		rw := jat.WrapPOST("/", map[string]int{"a": 1}).WithCharset("utf-8")
+		rw.Unwrap()
+
+		req := rw.WithForm(map[string]interface{}{"b": 2}).Unwrap()
+
+		assert.Equal(t, jat.ContentTypeForm+"; charset=utf-8", req.Header.Get("Content-Type"))
+	})
+}
+
+func TestCharset(t *testing.T) {
+	tests := map[string]struct {
+		f func(wrapper *jat.RequestWrapper)
+
+		wanted string
+	}{
+		"json": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithBody(map[string]string{"email": "foo@bar.com"})
+			},
+
+			wanted: "application/json; charset=utf-8",
+		},
+
+		"form": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithForm(map[string]interface{}{"email": "foo@bar.com"})
+			},
+
+			wanted: "application/x-www-form-urlencoded; charset=utf-8",
+		},
+
+		"charset kept": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.SetContentType("text/plain; charset=iso-8859-1")
+			},
+
+			wanted: "text/plain; charset=iso-8859-1",
+		},
+
+		"json api": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithJSONAPI()
+			},
+
+			wanted: jat.ContentTypeJSONAPI,
+		},
+
+		"binary": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.SetContentType("application/octet-stream")
+			},
+
+			wanted: "application/octet-stream",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wrapper := jat.WrapPOST("/", nil).WithCharset("utf-8")
+			test.f(wrapper)
+
+			req := wrapper.Unwrap()
+
+			assert.Equal(t, test.wanted, req.Header.Get("Content-Type"))
+		})
+	}
+}
+
+func TestBodyXML(t *testing.T) {
+	type user struct {
+		XMLName xml.Name `xml:"user"`
+		Email   string   `xml:"email"`
+	}
+
+	tests := map[string]struct {
+		body interface{}
+
+		wanted string
+	}{
+		"body from struct": {
+			body: user{Email: "foo@bar.com"},
+
+			wanted: "<user><email>foo@bar.com</email></user>",
+		},
+
+		"body from io.Reader": {
+			body: strings.NewReader("<user><email>foo@bar.com</email></user>"),
+
+			wanted: "<user><email>foo@bar.com</email></user>",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/", nil).
+				WithXML(test.body).
+				Unwrap()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+			assert.Equal(t, "application/xml", req.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("invalid body", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithXML(jat.POST("/", nil), make(chan int))
+		})
+	})
+}
+
+func TestNDJSON(t *testing.T) {
+	t.Run("items", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithNDJSON(
+				map[string]string{"email": "foo@bar.com"},
+				[]int{1, 2},
+				"hello",
+			).
+			Unwrap()
+
+		wanted := "{\"email\":\"foo@bar.com\"}\n[1,2]\n\"hello\""
+
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, wanted, string(b))
+		assert.Equal(t, int64(len(wanted)), req.ContentLength)
+		assert.Equal(t, jat.ContentTypeNDJSON, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("invalid item", func(t *testing.T) {
+		_, err := jat.WrapPOST("/", nil).
+			WithNDJSON("hello", make(chan int)).
+			TryUnwrap()
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "index 1")
+		}
+	})
+}
+
+func TestForm(t *testing.T) {
+	tests := map[string]struct {
+		data map[string]interface{}
+
+		wanted string
+	}{
+		"string values": {
+			data: map[string]interface{}{
+				"email":    "foo@bar.com",
+				"password": "secret",
+			},
+
+			wanted: "email=foo%40bar.com&password=secret",
+		},
+
+		"non-string values": {
+			data: map[string]interface{}{
+				"age":    18,
+				"active": true,
+			},
+
+			wanted: "active=true&age=18",
+		},
+
+		"slice values": {
+			data: map[string]interface{}{
+				"id": []int{1, 2, 3},
+			},
+
+			wanted: "id=1&id=2&id=3",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/", nil).
+				WithForm(test.data).
+				Unwrap()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+			assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+			assert.NoError(t, req.ParseForm())
+		})
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	t.Run("fields and files", func(t *testing.T) {
+		req := jat.WrapPOST("/upload", nil).
+			WithMultipart(map[string]interface{}{
+				"name": "avatar",
+				"tag":  []string{"a", "b"},
+			}).
+			WithFile("file", "avatar.png", strings.NewReader("png content")).
+			Unwrap()
+
+		assert.Contains(t, req.Header.Get("Content-Type"), "multipart/form-data; boundary=")
+		assert.NoError(t, req.ParseMultipartForm(1<<20))
+
+		assert.Equal(t, []string{"avatar"}, req.MultipartForm.Value["name"])
+		assert.Equal(t, []string{"a", "b"}, req.MultipartForm.Value["tag"])
+
+		assertFile(t, req, "file", "avatar.png", "png content")
+	})
+
+	t.Run("multiple files", func(t *testing.T) {
+		req := jat.WrapPOST("/upload", nil).
+			WithFile("first", "a.txt", strings.NewReader("aaa")).
+			WithFile("second", "b.txt", strings.NewReader("bbb")).
+			Unwrap()
+
+		assert.NoError(t, req.ParseMultipartForm(1<<20))
+
+		assertFile(t, req, "first", "a.txt", "aaa")
+		assertFile(t, req, "second", "b.txt", "bbb")
+	})
+
+	t.Run("content length", func(t *testing.T) {
+		req := jat.WrapPOST("/upload", nil).
+			WithFile("file", "a.txt", strings.NewReader("aaa")).
+			Unwrap()
+
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(b)), req.ContentLength)
+	})
+}
+
+func TestMultipartBoundary(t *testing.T) {
+	build := func() *http.Request {
+		return jat.WrapPOST("/upload", nil).
+			WithFile("file", "a.txt", strings.NewReader("aaa")).
+			WithMultipartBoundary("fixed-boundary").
+			WithMultipart(map[string]interface{}{"name": "a"}).
+			Unwrap()
+	}
+
+	first, second := build(), build()
+
+	assert.Equal(t, "multipart/form-data; boundary=fixed-boundary", first.Header.Get("Content-Type"))
+
+	firstBody, err := ioutil.ReadAll(first.Body)
+	assert.NoError(t, err)
+
+	secondBody, err := ioutil.ReadAll(second.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, string(firstBody), string(secondBody))
+	assert.Contains(t, string(firstBody), "--fixed-boundary--")
+
+	t.Run("invalid boundary", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithMultipartBoundary(jat.POST("/upload", nil), strings.Repeat("a", 71), nil)
+		})
+	})
+}
+
+func assertFile(t *testing.T, req *http.Request, field, fileName, content string) {
+	t.Helper()
+
+	f, header, err := req.FormFile(field)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, fileName, header.Filename)
+	assert.Equal(t, content, string(b))
+}
+
+func TestFormValues(t *testing.T) {
+	req := jat.WrapPOST("/", nil).
+		WithFormValues(url.Values{
+			"email": {"foo@bar.com"},
+			"id":    {"1", "2"},
+		}).
+		Unwrap()
+
+	wanted := "email=foo%40bar.com&id=1&id=2"
+
+	b, err := ioutil.ReadAll(req.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, wanted, string(b))
+	assert.Equal(t, int64(len(wanted)), req.ContentLength)
+	assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+}
+
+func TestIndexedForm(t *testing.T) {
+	req := jat.WrapPOST("/", nil).
+		WithIndexedForm("items", []map[string]interface{}{
+			{"name": "a", "qty": 1},
+			{"name": "b", "tag": []string{"x", "y"}},
+		}).
+		Unwrap()
+
+	assert.NoError(t, req.ParseForm())
+
+	wanted := url.Values{
+		"items[0][name]":  {"a"},
+		"items[0][qty]":   {"1"},
+		"items[1][name]":  {"b"},
+		"items[1][tag][]": {"x", "y"},
+	}
+
+	assert.Equal(t, wanted, req.PostForm)
+	assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+}
+
+func TestFormField(t *testing.T) {
+	t.Run("add fields", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			AddFormField("email", "foo@bar.com").
+			AddFormField("id", "1").
+			AddFormField("id", "2").
+			Unwrap()
+
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "email=foo%40bar.com&id=1&id=2", string(b))
+		assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("add field to form", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			WithForm(map[string]interface{}{"email": "foo@bar.com"}).
+			AddFormField("password", "secret").
+			Unwrap()
+
+		b, err := ioutil.ReadAll(req.Body)
+		assert.NoError(t, err)
+
+		assert.Equal(t, "email=foo%40bar.com&password=secret", string(b))
+	})
+
+	t.Run("add fields to json body", func(t *testing.T) {
+		req := jat.WrapPOST("/", map[string]string{"email": "foo@bar.com"}).
+			AddFormField("a", "1").
+			AddFormField("b", "2").
+			Unwrap()
+
+		assert.Equal(t, "a=1&b=2", string(jat.RawBody(req)))
+		assert.Equal(t, jat.ContentTypeForm, req.Header.Get("Content-Type"))
+	})
+
+	t.Run("replace form fields with json", func(t *testing.T) {
+		req := jat.WrapPOST("/", nil).
+			AddFormField("a", "1").
+			WithBody(map[string]int{"b": 2}).
+			Unwrap()
+
+		assert.Equal(t, `{"b":2}`, string(jat.RawBody(req)))
+		assert.Equal(t, jat.ContentTypeJSON, req.Header.Get("Content-Type"))
+	})
+}
+
+func TestHeader(t *testing.T) {
+	target := "/api/ping"
+
+	t.Run("add header", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			AddHeader("Host", "localhost:3000").
+			Unwrap()
+
+		wanted := http.Header{
+			"Host": []string{"localhost:3000"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("add header twice", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			AddHeader("Host", "localhost:3000").
+			AddHeader("Host", "localhost:8080").
+			Unwrap()
+
+		wanted := http.Header{
+			"Host": []string{"localhost:3000", "localhost:8080"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("set header twice", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			AddHeader("Host", "localhost:3000").
+			AddHeader("Host", "localhost:8080").
+			SetHeader("Host", "localhost:5432").
+			Unwrap()
+
+		wanted := http.Header{
+			"Host": []string{"localhost:5432"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("delete header", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			AddHeader("Host", "localhost:3000").
+			SetBearerAuth("6eTUFP4HNhvvIwz5nNiL").
+			DeleteHeader("Authorization").
+			Unwrap()
+
+		wanted := http.Header{
+			"Host": []string{"localhost:3000"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("headers from map", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			AddHeader("Host", "localhost:3000").
+			AddHeader("Accept", "text/html").
+			WithHeaders(map[string]string{
+				"accept":    "application/json",
+				"X-Request": "1",
+			}).
+			WithHeaderValues(http.Header{
+				"x-tag": []string{"a", "b"},
+			}).
+			Unwrap()
+
+		wanted := http.Header{
+			"Host":      []string{"localhost:3000"},
+			"Accept":    []string{"application/json"},
+			"X-Request": []string{"1"},
+			"X-Tag":     []string{"a", "b"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("raw header", func(t *testing.T) {
+		rw := jat.WrapGET(target).
+			AddRawHeader("x-request-id", "1").
+			AddRawHeader("x-request-id", "2").
+			SetRawHeader("X-TRACE", "abc")
+
+		wanted := http.Header{
+			"x-request-id": []string{"1", "2"},
+			"X-TRACE":      []string{"abc"},
+		}
+
+		if !reflect.DeepEqual(rw.Unwrap().Header, wanted) {
+			t.Error("unexpected header")
+		}
+
+		assert.Contains(t, rw.DumpHead(), "x-request-id: 1\r\n")
+	})
+
+	t.Run("method override", func(t *testing.T) {
+		req := jat.WrapPOST(target, nil).
+			WithMethodOverride(http.MethodPatch).
+			Unwrap()
+
+		assert.Equal(t, http.MethodPost, req.Method)
+		assert.Equal(t, http.MethodPatch, req.Header.Get("X-HTTP-Method-Override"))
+	})
+
+	t.Run("accept", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			WithAccept("application/json", "text/html;q=0.9").
+			WithAcceptLanguage("vi-VN", "en;q=0.8").
+			Unwrap()
+
+		wanted := http.Header{
+			"Accept":          []string{"application/json, text/html;q=0.9"},
+			"Accept-Language": []string{"vi-VN, en;q=0.8"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("conditional request", func(t *testing.T) {
+		modified := time.Date(2020, time.May, 1, 15, 4, 5, 0, time.FixedZone("ICT", 7*60*60))
+
+		req := jat.WrapGET(target).
+			WithIfNoneMatch(`"33a64df5"`).
+			WithIfMatch(`"bfc13a64"`).
+			WithIfModifiedSince(modified).
+			Unwrap()
+
+		wanted := http.Header{
+			"If-None-Match":     []string{`"33a64df5"`},
+			"If-Match":          []string{`"bfc13a64"`},
+			"If-Modified-Since": []string{"Fri, 01 May 2020 08:04:05 GMT"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("range", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			WithRange(0, 499).
+			Unwrap()
+
+		assert.Equal(t, "bytes=0-499", req.Header.Get("Range"))
+	})
+
+	t.Run("range from", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			WithRangeFrom(500).
+			Unwrap()
+
+		assert.Equal(t, "bytes=500-", req.Header.Get("Range"))
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WrapGET(target).WithRange(500, 499)
+		})
+	})
+
+	t.Run("set user agent twice", func(t *testing.T) {
+		req := jat.WrapGET(target).
+			WithUserAgent("jat/1.0").
+			WithUserAgent("jat/2.0").
+			Unwrap()
+
+		wanted := http.Header{
+			"User-Agent": []string{"jat/2.0"},
+		}
+
+		if !reflect.DeepEqual(req.Header, wanted) {
+			t.Error("unexpected header")
+		}
+	})
+
+	t.Run("set basic auth", func(t *testing.T) {
+		username, password := "foo", "bar"
+		req := jat.WrapGET(target).
+			SetBasicAuth(username, password).
+			Unwrap()
+
+		gotU, gotP, ok := req.BasicAuth()
+
+		assert.True(t, ok)
+		assert.Equal(t, username, gotU)
+		assert.Equal(t, password, gotP)
+	})
+
+	t.Run("add bearer auth", func(t *testing.T) {
+		token := "6eTUFP4HNhvvIwz5nNiL"
+		req := jat.WrapGET(target).
+			SetBearerAuth(token).
+			Unwrap()
+
+		gotToken := req.Header.Get("Authorization")
+
+		assert.Equal(t, "Bearer "+token, gotToken)
+	})
+
+	t.Run("set api key", func(t *testing.T) {
+		key := "6eTUFP4HNhvvIwz5nNiL"
+		req := jat.WrapGET(target).
+			SetAPIKey("X-Custom-Key", key).
+			Unwrap()
+
+		assert.Equal(t, key, req.Header.Get("X-Custom-Key"))
+	})
+
+	t.Run("set default api key", func(t *testing.T) {
+		key := "6eTUFP4HNhvvIwz5nNiL"
+		req := jat.WrapGET(target).
+			SetAPIKeyDefault(key).
+			Unwrap()
+
+		assert.Equal(t, key, req.Header.Get("X-API-Key"))
+	})
+
+	t.Run("add cookie", func(t *testing.T) {
+		username, password := "foo", "bar"
+		req := jat.WrapGET(target).
+			AddCookie(&http.Cookie{Name: username, Value: password}).
+			Unwrap()
+
+		gotC, err := req.Cookie(username)
+
+		assert.NoError(t, err)
+		assert.Equal(t, password, gotC.Value)
+	})
+}
+
+func TestCookies(t *testing.T) {
+	t.Run("add cookies", func(t *testing.T) {
+		req := jat.WrapGET("/api/ping").
+			AddCookies(
+				&http.Cookie{Name: "foo", Value: "1"},
+				&http.Cookie{Name: "bar", Value: "2"},
+			).
+			Unwrap()
+
+		assert.Equal(t, "foo=1; bar=2", req.Header.Get("Cookie"))
+	})
+
+	t.Run("set existing cookie", func(t *testing.T) {
+		req := jat.WrapGET("/api/ping").
+			AddCookie(&http.Cookie{Name: "foo", Value: "1"}).
+			AddCookie(&http.Cookie{Name: "bar", Value: "2"}).
+			AddCookie(&http.Cookie{Name: "foo", Value: "3"}).
+			SetCookie(&http.Cookie{Name: "foo", Value: "4"}).
+			Unwrap()
+
+		assert.Equal(t, "bar=2; foo=4", req.Header.Get("Cookie"))
+	})
+
+	t.Run("set new cookie", func(t *testing.T) {
+		req := jat.WrapGET("/api/ping").
+			AddCookie(&http.Cookie{Name: "bar", Value: "2"}).
+			SetCookie(&http.Cookie{Name: "foo", Value: "1"}).
+			Unwrap()
+
+		assert.Equal(t, "bar=2; foo=1", req.Header.Get("Cookie"))
+	})
+
+	t.Run("cookie jar", func(t *testing.T) {
+		jar, err := cookiejar.New(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		u, _ := url.Parse("http://localhost:3000/")
+		jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+
+		req := jat.WrapGET("http://localhost:3000/api/ping").
+			WithCookieJar(jar).
+			Unwrap()
+
+		c, err := req.Cookie("session")
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", c.Value)
+	})
+
+	t.Run("cookie jar with relative URL", func(t *testing.T) {
+		jar, err := cookiejar.New(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Panics(t, func() {
+			jat.WrapGET("/api/ping").WithCookieJar(jar)
+		})
+	})
+}
+
+func TestQuery(t *testing.T) {
+	tests := map[string]struct {
+		initURI string
+
+		f func(wrapper *jat.RequestWrapper)
+
+		wanted string // Note: query should be sorted by key
+	}{
+		"add query": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.AddQuery("type", "code")
+			},
+
+			wanted: "/api/ping?type=code",
+		},
+
+		"add query integer": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.AddQuery("num", 1)
+			},
+
+			wanted: "/api/ping?num=1",
+		},
+
+		"set query values": {
+			initURI: "/api/ping?id=0&type=code",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.SetQueryValues("id", 1, 2)
+			},
+
+			wanted: "/api/ping?id=1&id=2&type=code",
+		},
+
+		"set no query value": {
+			initURI: "/api/ping?id=0&type=code",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.SetQueryValues("id")
+			},
+
+			wanted: "/api/ping?type=code",
+		},
+
+		"add typed queries": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQueryInt("num", 1).
+					AddQueryBool("active", true).
+					AddQueryFloat("amount", 1e6).
+					AddQueryFloat("rate", 0.25)
+			},
+
+			wanted: "/api/ping?active=true&amount=1000000&num=1&rate=0.25",
+		},
+
+		"add two queries": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google")
+			},
+
+			wanted: "/api/ping?provider=google&type=code",
+		},
+
+		"add query twice": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("type", "token")
+			},
+
+			wanted: "/api/ping?type=code&type=token",
+		},
+
+		"add query array": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("id", 1).
+					AddQueryArray("id", 2, 3)
+			},
+
+			wanted: "/api/ping?id=1&id=2&id=3",
+		},
+
+		"set query": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google").
+					SetQuery("type", "money")
+			},
+
+			wanted: "/api/ping?provider=google&type=money",
+		},
+
+		"delete query": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("type", "token").
+					AddQuery("provider", "google").
+					DeleteQuery("type")
+			},
+
+			wanted: "/api/ping?provider=google",
+		},
+
+		"delete missing query": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("provider", "google").
+					DeleteQuery("type")
+			},
+
+			wanted: "/api/ping?provider=google",
+		},
+
+		"query from string": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithQueryString("provider=google&type=money")
+			},
+
+			wanted: "/api/ping?provider=google&type=money",
+		},
+
+		"add query from string": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQueryString("provider=google&type=money")
+			},
+
+			wanted: "/api/ping?provider=google&type=code&type=money",
+		},
+
+		"query from map": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithQuery(map[string][]interface{}{
+					"provider": {"google"},
+					"int":      {1},
+					"float":    {12.34},
+					"negative": {-5},
+				})
+			},
+
+			wanted: "/api/ping?float=12.34&int=1&negative=-5&provider=google",
+		},
+
+		"add query from url.Values": {
+			initURI: "/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQueryValues(url.Values{
+						"provider": {"google"},
+						"type":     {"token", "money"},
+					})
+			},
+
+			wanted: "/api/ping?provider=google&type=code&type=token&type=money",
+		},
+
+		"query from url.Values": {
+			initURI: "localhost:3000/api/ping",
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.WithQueryValues(url.Values{
+					"provider": {"google"},
+					"type":     {"money"},
+				})
+			},
+
+			wanted: "localhost:3000/api/ping?provider=google&type=money",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wrapper := jat.WrapGET(test.initURI)
+			test.f(wrapper)
+
+			req := wrapper.Unwrap()
+
+			got := req.URL.String()
+			if test.wanted != got {
+				t.Errorf("unexpected query: wanted %q but got %q", test.wanted, got)
+			}
+		})
+	}
+}
+
+func TestParam(t *testing.T) {
+	tests := map[string]struct {
+		template string
+		param    map[string]interface{}
+
+		wantedPath string
+	}{
+		"int param": {
+			template: "/users/:id",
+			param: map[string]interface{}{
+				"id": 1,
+			},
+
+			wantedPath: "/users/1",
+		},
+
+		"string param": {
+			template: "/users/:id/courses/:course_name",
+			param: map[string]interface{}{
+				"id":          1,
+				"course_name": "cs50",
+			},
+
+			wantedPath: "/users/1/courses/cs50",
+		},
+
+		"more param than template": {
+			template: "/users/:id",
+			param: map[string]interface{}{
+				"id":          1,
+				"course_name": "cs50",
+			},
+
+			wantedPath: "/users/1",
+		},
+
+		"less param than template": {
+			template: "/users/:id/courses/:course_name",
+			param: map[string]interface{}{
+				"id":          1,
+			},
+
+			wantedPath: "/users/1/courses/:course_name",
+		},
+
+		"repeated param": {
+			template: "/a/:id/b/:id",
+			param: map[string]interface{}{
+				"id": 1,
+			},
+
+			wantedPath: "/a/1/b/1",
+		},
+
+		"repeated param with other params": {
+			template: "/users/:id/courses/:course_name/users/:id",
+			param: map[string]interface{}{
+				"id":          1,
+				"course_name": "cs50",
+			},
+
+			wantedPath: "/users/1/courses/cs50/users/1",
+		},
+
+		"similar key name": {
+			template: "/users/:id/:id_string",
+			param: map[string]interface{}{
+				"id":         1,
+				"id_string": "cs50",
+			},
+
+			wantedPath: "/users/1/cs50",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapGET(test.template).
+				WithParam(test.param).
+				Unwrap()
+
+			assert.Equal(t, test.wantedPath, req.URL.Path)
+		})
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			rw := jat.WrapGET(test.template)
+			for k, v := range test.param {
+				rw.SetParam(k, v)
+			}
+
+			req := rw.Unwrap()
+
+			assert.Equal(t, test.wantedPath, req.URL.Path)
+		})
+	}
+}
+
+func TestParamStyle(t *testing.T) {
+	tests := map[string]struct {
+		style    jat.ParamStyle
+		template string
+		param    map[string]interface{}
+
+		wantedPath string
+	}{
+		"brace style": {
+			style:    jat.BraceStyle,
+			template: "/users/{id}/courses/{course_name}",
+			param: map[string]interface{}{
+				"id":          1,
+				"course_name": "cs50",
+			},
+
+			wantedPath: "/users/1/courses/cs50",
+		},
+
+		"brace style similar key name": {
+			style:    jat.BraceStyle,
+			template: "/users/{id}/{id_string}",
+			param: map[string]interface{}{
+				"id": 1,
+			},
+
+			wantedPath: "/users/1/{id_string}",
+		},
+
+		"brace style ignore colon": {
+			style:    jat.BraceStyle,
+			template: "/users/:id/{id}",
+			param: map[string]interface{}{
+				"id": 1,
+			},
+
+			wantedPath: "/users/:id/1",
+		},
+
+		"custom style": {
+			style: func(key string) string {
+				return `<` + key + `>`
+			},
+			template: "/users/<id>",
+			param: map[string]interface{}{
+				"id": 1,
+			},
+
+			wantedPath: "/users/1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapGET(test.template).
+				WithParamStyle(test.style).
+				WithParam(test.param).
+				Unwrap()
+
+			assert.Equal(t, test.wantedPath, req.URL.Path)
+		})
+	}
+}
+
+func TestQueryStruct(t *testing.T) {
+	type Page struct {
+		Page int `url:"page,omitempty"`
+		Size int `url:"size,omitempty"`
+	}
+
+	type Filter struct {
+		Page
+
+		Name     string `url:"name"`
+		IDs      []int  `url:"id"`
+		Active   *bool  `url:"active,omitempty"`
+		Token    string `url:"-"`
+		Provider string
+		internal string
+	}
+
+	active := true
+
+	tests := map[string]struct {
+		query interface{}
+
+		wanted string
+	}{
+		"all fields": {
+			query: Filter{
+				Page:     Page{Page: 1, Size: 10},
+				Name:     "foo",
+				IDs:      []int{1, 2},
+				Active:   &active,
+				Token:    "secret",
+				Provider: "google",
+				internal: "internal",
+			},
+
+			wanted: "/api/users?Provider=google&active=true&id=1&id=2&name=foo&page=1&size=10",
+		},
+
+		"omit empty": {
+			query: &Filter{
+				Name: "foo",
+			},
+
+			wanted: "/api/users?Provider=&name=foo",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapGET("/api/users").
+				AddQuery("type", "code").
+				WithQueryStruct(test.query).
+				Unwrap()
+
+			assert.Equal(t, test.wanted, req.URL.String())
+		})
+	}
+
+	t.Run("not a struct", func(t *testing.T) {
+		assert.Panics(t, func() {
+			jat.WithQueryStruct(jat.GET("/api/users"), map[string]string{})
+		})
+	})
+}
+
+func TestTimeFormat(t *testing.T) {
+	at := time.Date(2020, 5, 17, 8, 30, 0, 0, time.UTC)
+
+	t.Run("default format", func(t *testing.T) {
+		req := jat.WrapGET("/events").
+			AddQuery("from", at).
+			SetHeaderTime("X-Since", at).
+			Unwrap()
+
+		assert.Equal(t, "2020-05-17T08:30:00Z", req.URL.Query().Get("from"))
+		assert.Equal(t, "2020-05-17T08:30:00Z", req.Header.Get("X-Since"))
+	})
+
+	t.Run("custom format", func(t *testing.T) {
+		req := jat.WrapGET("/events").
+			WithTimeFormat("2006-01-02").
+			AddQueryArray("day", at, at.AddDate(0, 0, 1)).
+			WithOrderedQuery().
+			SetQuery("from", &at).
+			AddHeaderTime("X-Since", at).
+			Unwrap()
+
+		assert.Equal(t, "day=2020-05-17&day=2020-05-18&from=2020-05-17", req.URL.RawQuery)
+		assert.Equal(t, "2020-05-17", req.Header.Get("X-Since"))
+	})
+
+	t.Run("replace query", func(t *testing.T) {
+		rw := jat.WrapGET("/events").WithTimeFormat("2006-01-02")
+
+		req := rw.WithQuery(map[string][]interface{}{"from": {at}}).Unwrap()
+
+		assert.Equal(t, "from=2020-05-17", req.URL.RawQuery)
+
+		req = rw.WithQueryStruct(struct {
+			From  time.Time  `url:"from"`
+			Until *time.Time `url:"until"`
+		}{From: at, Until: &at}).Unwrap()
+
+		assert.Equal(t, "from=2020-05-17&until=2020-05-17", req.URL.RawQuery)
+	})
+
+	t.Run("nested query", func(t *testing.T) {
+		req := jat.WrapGET("/events").
+			WithTimeFormat("2006-01-02").
+			WithNestedQuery("filter", map[string]interface{}{
+				"from": at,
+				"days": []time.Time{at},
+			}).
+			Unwrap()
+
+		assert.Equal(t, "2020-05-17", req.URL.Query().Get("filter[from]"))
+		assert.Equal(t, "2020-05-17", req.URL.Query().Get("filter[days][]"))
+
+		req = jat.GET("/events")
+		jat.WithNestedQuery(req, "filter", map[string]interface{}{"from": at})
+
+		assert.Equal(t, "2020-05-17T08:30:00Z", req.URL.Query().Get("filter[from]"))
+	})
+
+	t.Run("package function", func(t *testing.T) {
+		req := jat.GET("/events")
+		jat.SetQuery(req, "from", at)
+
+		assert.Equal(t, "from=2020-05-17T08%3A30%3A00Z", req.URL.RawQuery)
+	})
+}
+
+func TestSpaceEncoding(t *testing.T) {
+	t.Run("default", func(t *testing.T) {
+		req := jat.WrapGET("/search?q=a%20b").
+			AddQuery("sort", "created at").
+			Unwrap()
+
+		assert.Equal(t, "q=a+b&sort=created+at", req.URL.RawQuery)
+
+		req = jat.WrapGET("/search?q=a%20b").Unwrap()
+
+		assert.Equal(t, "q=a%20b", req.URL.RawQuery)
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		rw := jat.WrapGET("/search").
+			WithSpaceEncoding(jat.SpacePercent).
+			AddQuery("q", "a b+c").
+			WithBaseURL("http://api.test")
+
+		assert.Contains(t, rw.CurlString(), "'http://api.test/search?q=a%20b%2Bc'")
+
+		req := rw.AddQuery("sort", "created at").Unwrap()
+
+		assert.Equal(t, "q=a%20b%2Bc&sort=created%20at", req.URL.RawQuery)
+		assert.Equal(t, "created at", req.URL.Query().Get("sort"))
+	})
+
+	t.Run("plus", func(t *testing.T) {
+		req := jat.GET("/search?q=a%20b")
+		jat.WithSpaceEncoding(req, jat.SpacePlus)
+
+		assert.Equal(t, "q=a+b", req.URL.RawQuery)
+	})
+}
+
+func TestSemicolonQuery(t *testing.T) {
+	t.Run("wrapper", func(t *testing.T) {
+		rw := jat.WrapGET("/search").
+			WithSemicolonQuery().
+			AddQuery("q", "a&b").
+			AddQuery("page", 1).
+			WithBaseURL("http://api.test")
+
+		assert.Contains(t, rw.CurlString(), "'http://api.test/search?page=1;q=a%26b'")
+		assert.Contains(t, rw.DumpHead(), "GET /search?page=1;q=a%26b HTTP/1.1")
+
+		req := rw.AddQuery("sort", "name").Unwrap()
+
+		assert.Equal(t, "page=1;q=a%26b;sort=name", req.URL.RawQuery)
+	})
+
+	t.Run("default", func(t *testing.T) {
+		req := jat.WrapGET("/search").AddQuery("q", "a").AddQuery("page", 1).Unwrap()
+
+		assert.Equal(t, "page=1&q=a", req.URL.RawQuery)
+	})
+
+	t.Run("request", func(t *testing.T) {
+		req := jat.GET("/search?q=a&page=1")
+		jat.WithSemicolonQuery(req)
+
+		assert.Equal(t, "q=a;page=1", req.URL.RawQuery)
+	})
+
+	t.Run("change after rewrite", func(t *testing.T) {
+		req := jat.GET("/x?a=1&b=2")
+		jat.WithSemicolonQuery(req)
+		jat.AddQuery(req, "c", 3)
+
+		assert.Equal(t, "a=1&b=2&c=3", req.URL.RawQuery)
+
+		rw := jat.WrapGET("/x?a=1&b=2").WithSemicolonQuery()
+		rw.Unwrap()
+
+		req = rw.SetQuery("a", 0).DeleteQuery("b").AddQuery("c", 3).Unwrap()
+
+		assert.Equal(t, "a=0;c=3", req.URL.RawQuery)
+		assert.Equal(t, []string{"0"}, rw.Query()["a"])
+
+		req = jat.WrapGET("/x?a=1&b=2").
+			WithSemicolonQuery().
+			WithOrderedQuery().
+			Use(jat.WithSemicolonQuery).
+			AddQuery("c", 3).
+			Unwrap()
+
+		assert.Equal(t, "a=1;b=2;c=3", req.URL.RawQuery)
+	})
+}
+
+func TestOrderedQuery(t *testing.T) {
+	tests := map[string]struct {
+		f func(wrapper *jat.RequestWrapper)
+
+		wanted string
+	}{
+		"add query": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google").
+					AddQuery("type", "token")
+			},
+
+			wanted: "/api/ping?type=code&provider=google&type=token",
 		},
 
-		"less param than template": {
-			template: "/users/:id/courses/:course_name",
+		"set query": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google").
+					AddQuery("type", "token").
+					SetQuery("type", "money").
+					SetQuery("amount", 10)
+			},
+
+			wanted: "/api/ping?type=money&provider=google&amount=10",
+		},
+
+		"set query values": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google").
+					AddQuery("type", "token").
+					SetQueryValues("type", "money", "card").
+					SetQueryValues("amount", 10, 20)
+			},
+
+			wanted: "/api/ping?type=money&type=card&provider=google&amount=10&amount=20",
+		},
+
+		"delete query": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQuery("provider", "google").
+					DeleteQuery("type")
+			},
+
+			wanted: "/api/ping?provider=google",
+		},
+
+		"add query from url.Values": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQueryValues(url.Values{
+						"type":     {"token"},
+						"provider": {"google"},
+					})
+			},
+
+			wanted: "/api/ping?type=code&provider=google&type=token",
+		},
+
+		"add query from string": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					AddQuery("type", "code").
+					AddQueryString("provider=google&type=money")
+			},
+
+			wanted: "/api/ping?type=code&provider=google&type=money",
+		},
+
+		"query from string": {
+			f: func(wrapper *jat.RequestWrapper) {
+				wrapper.
+					WithQueryString("type=money&provider=google&email=foo%40bar.com").
+					AddQuery("amount", 10)
+			},
+
+			wanted: "/api/ping?type=money&provider=google&email=foo%40bar.com&amount=10",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			wrapper := jat.WrapGET("/api/ping").WithOrderedQuery()
+			test.f(wrapper)
+
+			req := wrapper.Unwrap()
+
+			assert.Equal(t, test.wanted, req.URL.String())
+		})
+	}
+}
+
+func TestBaseURL(t *testing.T) {
+	tests := map[string]struct {
+		base   string
+		target string
+
+		wanted string
+	}{
+		"base without path": {
+			base:   "https://api.test",
+			target: "/users",
+
+			wanted: "https://api.test/users",
+		},
+
+		"base with trailing slash": {
+			base:   "https://api.test/",
+			target: "/users",
+
+			wanted: "https://api.test/users",
+		},
+
+		"base with path": {
+			base:   "https://api.test/v1",
+			target: "/users",
+
+			wanted: "https://api.test/v1/users",
+		},
+
+		"base with path and trailing slash": {
+			base:   "https://api.test/v1/",
+			target: "/users",
+
+			wanted: "https://api.test/v1/users",
+		},
+
+		"target with query": {
+			base:   "https://api.test/v1",
+			target: "/users?type=code",
+
+			wanted: "https://api.test/v1/users?type=code",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapGET(test.target).
+				WithBaseURL(test.base).
+				Unwrap()
+
+			assert.Equal(t, test.wanted, req.URL.String())
+			assert.Equal(t, "api.test", req.Host)
+		})
+	}
+}
+
+func TestWrapURL(t *testing.T) {
+	u := &url.URL{
+		Scheme:   "https",
+		Host:     "api.test",
+		Path:     "/users",
+		RawQuery: "z=1&a=%7e",
+	}
+
+	req := jat.WrapURL(http.MethodPost, u, map[string]string{"email": "foo@bar.com"}).
+		SetHeader("X-Request-Id", "1").
+		Unwrap()
+
+	assert.Equal(t, "https://api.test/users?z=1&a=%7e", req.URL.String())
+	assert.Equal(t, "api.test", req.Host)
+	assert.NotNil(t, req.TLS)
+	assert.Equal(t, `{"email":"foo@bar.com"}`, string(jat.RawBody(req)))
+
+	req.URL.Path = "/courses"
+	assert.Equal(t, "/users", u.Path)
+
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		req = jat.WrapURL(method, u, nil).Unwrap()
+
+		assert.Empty(t, jat.RawBody(req), method)
+		assert.Equal(t, int64(0), req.ContentLength, method)
+	}
+}
+
+func TestFragment(t *testing.T) {
+	req := jat.WrapGET("/docs").
+		SetFragment("getting started").
+		AddQuery("lang", "en").
+		Unwrap()
+
+	assert.Equal(t, "getting started", req.URL.Fragment)
+	assert.Equal(t, "/docs?lang=en#getting%20started", req.URL.String())
+}
+
+func TestPathPrefix(t *testing.T) {
+	tests := map[string]struct {
+		prefix string
+		target string
+
+		wanted string
+	}{
+		"prefix without slashes": {
+			prefix: "api/v1",
+			target: "/users?type=code",
+
+			wanted: "/api/v1/users?type=code",
+		},
+
+		"prefix with trailing slash": {
+			prefix: "/api/v1/",
+			target: "/users",
+
+			wanted: "/api/v1/users",
+		},
+
+		"path already prefixed": {
+			prefix: "/api/v1",
+			target: "/api/v1/users",
+
+			wanted: "/api/v1/users",
+		},
+
+		"path with similar prefix": {
+			prefix: "/api/v1",
+			target: "/api/v10/users",
+
+			wanted: "/api/v1/api/v10/users",
+		},
+
+		"encoded path": {
+			prefix: "/api v1",
+			target: "/files/a%2Fb",
+
+			wanted: "/api%20v1/files/a%2Fb",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapGET(test.target).
+				WithPathPrefix(test.prefix).
+				Unwrap()
+
+			assert.Equal(t, test.wanted, req.URL.String())
+		})
+	}
+}
+
+func TestParamEscape(t *testing.T) {
+	tests := map[string]struct {
+		escape   bool
+		template string
+		param    map[string]interface{}
+
+		wantedPath string
+		wantedURL  string
+	}{
+		"raw slash": {
+			template: "/files/:name",
 			param: map[string]interface{}{
-				"id":          1,
+				"name": "a/b",
 			},
 
-			wantedPath: "/users/1/courses/:course_name",
+			wantedPath: "/files/a/b",
+			wantedURL:  "/files/a/b",
 		},
 
-		"similar key name": {
-			template: "/users/:id/:id_string",
+		"escape slash": {
+			escape:   true,
+			template: "/files/:name",
 			param: map[string]interface{}{
-				"id":         1,
-				"id_string": "cs50",
+				"name": "a/b",
 			},
 
-			wantedPath: "/users/1/cs50",
+			wantedPath: "/files/a/b",
+			wantedURL:  "/files/a%2Fb",
+		},
+
+		"escape space": {
+			escape:   true,
+			template: "/files/:name/:id",
+			param: map[string]interface{}{
+				"name": "a b",
+				"id":   1,
+			},
+
+			wantedPath: "/files/a b/1",
+			wantedURL:  "/files/a%20b/1",
+		},
+
+		"encoded template": {
+			template: "/files/a%2Fb/:name",
+			param: map[string]interface{}{
+				"name": "c",
+			},
+
+			wantedPath: "/files/a/b/c",
+			wantedURL:  "/files/a%2Fb/c",
+		},
+
+		"encoded placeholder": {
+			escape:   true,
+			template: "/files/a%2Fb/%3Aname.txt",
+			param: map[string]interface{}{
+				"name": "c d",
+			},
+
+			wantedPath: "/files/a/b/c d.txt",
+			wantedURL:  "/files/a%2Fb/c%20d.txt",
 		},
 	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
 			req := jat.WrapGET(test.template).
+				WithParamEscape(test.escape).
 				WithParam(test.param).
 				Unwrap()
 
 			assert.Equal(t, test.wantedPath, req.URL.Path)
+			assert.Equal(t, test.wantedURL, req.URL.String())
 		})
 	}
+}
+
+func TestParamEncodedBraceTemplate(t *testing.T) {
+	req := jat.WrapGET("/dirs/a%2Fb/%7Bid%7D").
+		WithParamStyle(jat.BraceStyle).
+		SetParam("id", 42).
+		Unwrap()
+
+	assert.Equal(t, "/dirs/a/b/42", req.URL.Path)
+	assert.Equal(t, "/dirs/a%2Fb/42", req.URL.EscapedPath())
+}
+
+func TestParamsFromQuery(t *testing.T) {
+	t.Run("colon style", func(t *testing.T) {
+		req := jat.WrapGET("/users/:id/courses/:course?id=1&course=cs50&page=2").
+			WithParamsFromQuery().
+			Unwrap()
+
+		assert.Equal(t, "/users/1/courses/cs50?page=2", req.URL.String())
+	})
+
+	t.Run("brace style", func(t *testing.T) {
+		req := jat.WrapGET("/users/{id}?id=a%20b&name=foo").
+			WithParamStyle(jat.BraceStyle).
+			WithParamEscape(true).
+			WithParamsFromQuery().
+			Unwrap()
+
+		assert.Equal(t, "/users/a%20b?name=foo", req.URL.String())
+	})
+
+	t.Run("package function", func(t *testing.T) {
+		req := jat.GET("/users/:id?id=1&ids=2")
+		jat.WithParamsFromQuery(req)
+
+		assert.Equal(t, "/users/1?ids=2", req.URL.String())
+	})
+}
+
+func TestMust(t *testing.T) {
+	tests := map[string]struct {
+		wrapper *jat.RequestWrapper
+
+		wantedErr string
+	}{
+		"relative path": {
+			wrapper: jat.WrapGET("/users/:id").SetParam("id", 1),
+		},
+
+		"absolute URL": {
+			wrapper: jat.WrapGET("https://api.test/users"),
+		},
+
+		"building error": {
+			wrapper: jat.WrapPOST("/users", nil).WithBody(make(chan int)),
+
+			wantedErr: "invalid JSON body",
+		},
+
+		"leftover param": {
+			wrapper: jat.WrapGET("/users/:id"),
+
+			wantedErr: "[:id]",
+		},
+
+		"invalid method": {
+			wrapper: func() *jat.RequestWrapper {
+				rw := jat.WrapGET("/users")
+				rw.Request.Method = "GET USERS"
+				return rw
+			}(),
+
+			wantedErr: `method should be a valid HTTP token "GET USERS"`,
+		},
+
+		"path without slash": {
+			wrapper: func() *jat.RequestWrapper {
+				rw := jat.WrapGET("/users")
+				rw.Request.URL.Path = "users"
+				return rw
+			}(),
+
+			wantedErr: `relative path "users" should start with a slash`,
+		},
+	}
 
 	for name, test := range tests {
 		t.Run(name, func(t *testing.T) {
-			rw := jat.WrapGET(test.template)
-			for k, v := range test.param {
-				rw.SetParam(k, v)
+			if test.wantedErr == "" {
+				assert.NotPanics(t, func() {
+					assert.Equal(t, test.wrapper, test.wrapper.Must())
+				})
+				return
 			}
 
-			req := rw.Unwrap()
+			defer func() {
+				err, ok := recover().(error)
+				if assert.True(t, ok) {
+					assert.Contains(t, err.Error(), "is not well-formed")
+					assert.Contains(t, err.Error(), test.wantedErr)
+				}
+			}()
 
-			assert.Equal(t, test.wantedPath, req.URL.Path)
+			test.wrapper.Must()
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestStrictParams(t *testing.T) {
+	t.Run("all params replaced", func(t *testing.T) {
+		req, err := jat.WrapGET("/users/:id/courses/:course_name").
+			WithStrictParams().
+			WithParam(map[string]interface{}{
+				"id":          1,
+				"course_name": "cs50",
+			}).
+			TryUnwrap()
+
+		assert.NoError(t, err)
+		assert.Equal(t, "/users/1/courses/cs50", req.URL.Path)
+	})
+
+	t.Run("less param than template", func(t *testing.T) {
+		rw := jat.WrapGET("/users/:id/courses/:course_name").
+			WithStrictParams().
+			WithParam(map[string]interface{}{
+				"id":         1,
+				"courseName": "cs50",
+			})
+
+		_, err := rw.TryUnwrap()
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), ":course_name")
+		}
+
+		assert.Panics(t, func() { rw.Unwrap() })
+	})
+
+	t.Run("brace style", func(t *testing.T) {
+		_, err := jat.WrapGET("/users/{id}/courses/{course_name}").
+			WithParamStyle(jat.BraceStyle).
+			WithStrictParams().
+			SetParam("id", 1).
+			TryUnwrap()
+
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "{course_name}")
+		}
+	})
+}
+
+func TestParamInQuery(t *testing.T) {
+	req := jat.WrapGET("/users/:id?id=:id&name=:name").
+		WithParam(map[string]interface{}{
+			"id":   1,
+			"name": "foo",
+		}).
+		Unwrap()
+
+	assert.Equal(t, "/users/1", req.URL.Path)
+	assert.Equal(t, "id=:id&name=:name", req.URL.RawQuery)
+}
+
+func TestRequestHost(t *testing.T) {
+	req := jat.WrapGET("http://localhost:3000/api/ping").
+		SetRequestHost("api.example.com").
+		Unwrap()
+
+	assert.Equal(t, "api.example.com", req.Host)
+	assert.Equal(t, "localhost:3000", req.URL.Host)
+	assert.Empty(t, req.Header.Get("Host"))
+}
+
+func TestNestedQuery(t *testing.T) {
+	req := jat.WrapGET("/api/users").
+		AddQuery("page", 1).
+		WithNestedQuery("filter", map[string]interface{}{
+			"name": "foo",
+			"age":  map[string]interface{}{"gt": 10},
+			"tag":  []string{"a", "b"},
+		}).
+		Unwrap()
+
+	wanted := url.Values{
+		"page":            {"1"},
+		"filter[name]":    {"foo"},
+		"filter[age][gt]": {"10"},
+		"filter[tag][]":   {"a", "b"},
+	}
+
+	assert.Equal(t, wanted, req.URL.Query())
+}
+
+func TestPathAndURL(t *testing.T) {
+	rw := jat.WrapGET("/users/:id").
+		SetParam("id", 1).
+		AddQuery("type", "code")
+
+	assert.Equal(t, "/users/1", rw.Path())
+	assert.Equal(t, "/users/1?type=code", rw.URL().String())
+
+	u := rw.URL()
+	u.Path = "/courses"
+
+	assert.Equal(t, "/users/1", rw.Path())
+}
+
+func TestQueryAccessor(t *testing.T) {
+	rw := jat.WrapGET("/users").
+		AddQuery("type", "code").
+		AddQuery("id", 1)
+
+	q := rw.Query()
+	assert.Equal(t, "code", q.Get("type"))
+	assert.Equal(t, "1", q.Get("id"))
+
+	q.Set("type", "token")
+	assert.Equal(t, "code", rw.Query().Get("type"))
+}
+
+func TestHeaderAccessor(t *testing.T) {
+	rw := jat.WrapGET("/users").
+		SetBearerAuth("token")
+
+	h := rw.Header()
+	assert.Equal(t, "Bearer token", h.Get("Authorization"))
+
+	h.Del("Authorization")
+	assert.Equal(t, "Bearer token", rw.Header().Get("Authorization"))
+}
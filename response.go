@@ -0,0 +1,79 @@
+package jat
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// ResponseWrapper wraps *http.Response for inspecting with fluent interface
+// Example:
+//
+//	var users []User
+//	err := WrapGET(server.URL + "/api/users").
+//		Send(client).
+//		ExpectStatus(http.StatusOK).
+//		DecodeJSON(&users)
+type ResponseWrapper struct {
+	Response *http.Response
+
+	body []byte
+	read bool
+}
+
+// WrapResponse wraps *http.Response and returns a *ResponseWrapper
+// for inspecting with fluent interface
+func WrapResponse(resp *http.Response) *ResponseWrapper {
+	return &ResponseWrapper{Response: resp}
+}
+
+// Unwrap returns the wrapped response
+func (rsp *ResponseWrapper) Unwrap() *http.Response {
+	return rsp.Response
+}
+
+// ExpectStatus panics if the status code of the response is not the expected code
+func (rsp *ResponseWrapper) ExpectStatus(code int) *ResponseWrapper {
+	if rsp.Response.StatusCode != code {
+		panic(fmt.Errorf("expected status %d but got %d, body: %s", code, rsp.Response.StatusCode, rsp.Body()))
+	}
+
+	return rsp
+}
+
+// Body reads and returns the whole body of the response,
+// the body is buffered, so it can be called multiple times
+// and the body of the wrapped response is still readable
+func (rsp *ResponseWrapper) Body() []byte {
+	if rsp.read {
+		return rsp.body
+	}
+
+	if rsp.Response.Body != nil {
+		b, err := ioutil.ReadAll(rsp.Response.Body)
+		if err != nil {
+			panic(fmt.Errorf("read response body failed %v", err))
+		}
+
+		_ = rsp.Response.Body.Close()
+
+		rsp.body = b
+		rsp.Response.Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+
+	rsp.read = true
+
+	return rsp.body
+}
+
+// BodyString is the same with Body but returns a string
+func (rsp *ResponseWrapper) BodyString() string {
+	return string(rsp.Body())
+}
+
+// DecodeJSON unmarshals the JSON body of the response into v
+func (rsp *ResponseWrapper) DecodeJSON(v interface{}) error {
+	return json.Unmarshal(rsp.Body(), v)
+}
@@ -0,0 +1,58 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"email": "foo@bar.com"}]`))
+	}))
+	defer server.Close()
+
+	t.Run("decode json", func(t *testing.T) {
+		var users []struct {
+			Email string `json:"email"`
+		}
+
+		err := jat.WrapGET(server.URL + "/users").
+			Send(server.Client()).
+			ExpectStatus(http.StatusOK).
+			DecodeJSON(&users)
+
+		assert.NoError(t, err)
+		if assert.Len(t, users, 1) {
+			assert.Equal(t, "foo@bar.com", users[0].Email)
+		}
+	})
+
+	t.Run("read body multiple times", func(t *testing.T) {
+		rsp := jat.WrapGET(server.URL + "/users").Send(server.Client())
+
+		assert.JSONEq(t, `[{"email": "foo@bar.com"}]`, rsp.BodyString())
+		assert.JSONEq(t, `[{"email": "foo@bar.com"}]`, rsp.BodyString())
+
+		b, err := ioutil.ReadAll(rsp.Unwrap().Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[{"email": "foo@bar.com"}]`, string(b))
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		rsp := jat.WrapGET(server.URL + "/courses").Send(server.Client())
+
+		assert.Panics(t, func() {
+			rsp.ExpectStatus(http.StatusOK)
+		})
+	})
+}
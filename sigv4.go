@@ -0,0 +1,191 @@
+package jat
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AWSCredentials is the credentials used by SignSigV4
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// SessionToken is sent in the X-Amz-Security-Token header if it is not empty,
+	// it is required by the temporary credentials
+	SessionToken string
+}
+
+const (
+	sigV4Algorithm  = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat = "20060102T150405Z"
+)
+
+// SignSigV4 sets the request's Authorization header to the AWS Signature Version 4
+// of the request for the region and service, e.g. s3. See: https://docs.aws.amazon.com/general/latest/gr/sigv4_signing.html
+// The X-Amz-Date header is used as the signing time if it is set, otherwise it is set to now,
+// and the X-Amz-Content-Sha256 header is set to the hash of the body as required by S3.
+// All the headers except Authorization and User-Agent are signed,
+// so it should be the last change to the request
+func SignSigV4(r *http.Request, creds AWSCredentials, region, service string) {
+	r.Header.Del("Authorization")
+
+	date := r.Header.Get("X-Amz-Date")
+	if date == "" {
+		date = time.Now().UTC().Format(sigV4TimeFormat)
+		r.Header.Set("X-Amz-Date", date)
+	}
+
+	t, err := time.Parse(sigV4TimeFormat, date)
+	if err != nil {
+		panic(fmt.Errorf("parse X-Amz-Date failed %v", err))
+	}
+
+	if creds.SessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	payloadHash := sha256Hex(RawBody(r))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, canonicalHeaders := sigV4Headers(r)
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		sigV4URI(r, service),
+		sigV4Query(r),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{t.Format("20060102"), region, service, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		date,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{t.Format("20060102"), region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		sigV4Algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// SignSigV4 signs the wrapped request with AWS Signature Version 4,
+// the options applied when unwrapping are applied first, so the signature stays valid
+func (rw *RequestWrapper) SignSigV4(creds AWSCredentials, region, service string) *RequestWrapper {
+	defer rw.trace("SignSigV4", creds.AccessKeyID, region, service)()
+
+	rw.finalize()
+	SignSigV4(rw.Request, creds, region, service)
+
+	return rw
+}
+
+// sigV4URI returns the canonical URI, every segment of the path is encoded once for S3
+// and twice for the other services
+func sigV4URI(r *http.Request, service string) string {
+	path := r.URL.Path
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = sigV4Escape(segment)
+		if service != "s3" {
+			segments[i] = sigV4Escape(segments[i])
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// sigV4Query returns the canonical query, the pairs are sorted by key then value
+func sigV4Query(r *http.Request) string {
+	var pairs []string
+	for key, values := range currentQuery(r) {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// sigV4Headers returns the signed headers and the canonical headers, including the host
+func sigV4Headers(r *http.Request) (string, string) {
+	host := r.Host
+	if host == "" {
+		host = r.URL.Host
+	}
+
+	values := map[string]string{"host": host}
+	for key, vs := range r.Header {
+		key = strings.ToLower(key)
+		if key == "authorization" || key == "user-agent" {
+			continue
+		}
+
+		trimmed := make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[key] = strings.Join(trimmed, ",")
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var canonical strings.Builder
+	for _, key := range keys {
+		canonical.WriteString(key + ":" + values[key] + "\n")
+	}
+
+	return strings.Join(keys, ";"), canonical.String()
+}
+
+// sigV4Escape encodes every byte except the unreserved characters of RFC 3986
+func sigV4Escape(s string) string {
+	var buf strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+			continue
+		}
+
+		fmt.Fprintf(&buf, "%%%02X", c)
+	}
+
+	return buf.String()
+}
+
+func sha256Hex(b []byte) string {
+	h := sha256.Sum256(b)
+	return hex.EncodeToString(h[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,35 @@
+package jat
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// WebSocket returns a GET request of the WebSocket opening handshake, see: RFC 6455, section 4.1
+// The Upgrade, Connection, Sec-WebSocket-Version and a random Sec-WebSocket-Key headers are set
+func WebSocket(target string) *http.Request {
+	r := GET(target)
+
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Sec-WebSocket-Key", newWebSocketKey())
+	r.Header.Set("Sec-WebSocket-Version", "13")
+
+	return r
+}
+
+func WrapWebSocket(target string) *RequestWrapper {
+	return Wrap(WebSocket(target))
+}
+
+// newWebSocketKey returns the base64 encoded random 16 bytes
+func newWebSocketKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(fmt.Errorf("generate websocket key failed %v", err))
+	}
+
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,25 @@
+package jat_test
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"net/http"
+	"testing"
+)
+
+func TestWebSocket(t *testing.T) {
+	req := jat.WrapWebSocket("/chat").AddQuery("room", "1").Unwrap()
+
+	assert.Equal(t, http.MethodGet, req.Method)
+	assert.Equal(t, "/chat?room=1", req.URL.String())
+	assert.Equal(t, "websocket", req.Header.Get("Upgrade"))
+	assert.Equal(t, "Upgrade", req.Header.Get("Connection"))
+	assert.Equal(t, "13", req.Header.Get("Sec-WebSocket-Version"))
+
+	key, err := base64.StdEncoding.DecodeString(req.Header.Get("Sec-WebSocket-Key"))
+	assert.NoError(t, err)
+	assert.Len(t, key, 16)
+
+	assert.NotEqual(t, req.Header.Get("Sec-WebSocket-Key"), jat.WebSocket("/chat").Header.Get("Sec-WebSocket-Key"))
+}
@@ -0,0 +1,43 @@
+package jat
+
+import (
+	"fmt"
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// YAMLCodec marshals the body with yaml.Marshal,
+// if the body is io.Reader, it will be used as raw YAML
+var YAMLCodec = rawCodec(ContentTypeYAML, marshalYAML)
+
+// WithYAML replaces the current body of the request with the YAML encoded body
+// and sets the Content-Type to application/yaml unless it has been set before.
+// If body is io.Reader, it will be used as raw YAML
+func WithYAML(r *http.Request, body interface{}) {
+	WithEncoded(r, YAMLCodec, body)
+}
+
+// WithYAML replaces the current body of the wrapped request with the YAML encoded body
+// The error is kept until Unwrap or TryUnwrap
+func (rw *RequestWrapper) WithYAML(body interface{}) *RequestWrapper {
+	defer rw.trace("WithYAML", body)()
+
+	rw.replaceBody(func() error {
+		return tryWithEncoded(rw.Request, YAMLCodec, body)
+	})
+
+	return rw
+}
+
+// marshalYAML is the same with yaml.Marshal, but returns an error
+// instead of panicking on the values it can not encode
+func marshalYAML(v interface{}) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	return yaml.Marshal(v)
+}
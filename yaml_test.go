@@ -0,0 +1,57 @@
+package jat_test
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/victornm/jat"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	type config struct {
+		Name  string   `yaml:"name"`
+		Hosts []string `yaml:"hosts"`
+	}
+
+	tests := map[string]struct {
+		body interface{}
+
+		wanted string
+	}{
+		"body from struct": {
+			body: config{Name: "api", Hosts: []string{"a.test", "b.test"}},
+
+			wanted: "name: api\nhosts:\n    - a.test\n    - b.test\n",
+		},
+
+		"body from io.Reader": {
+			body: strings.NewReader("name: api\n"),
+
+			wanted: "name: api\n",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := jat.WrapPOST("/configs", nil).
+				WithYAML(test.body).
+				Unwrap()
+
+			b, err := ioutil.ReadAll(req.Body)
+			assert.NoError(t, err)
+
+			assert.Equal(t, test.wanted, string(b))
+			assert.Equal(t, int64(len(test.wanted)), req.ContentLength)
+			assert.Equal(t, jat.ContentTypeYAML, req.Header.Get("Content-Type"))
+		})
+	}
+
+	t.Run("invalid body", func(t *testing.T) {
+		_, err := jat.WrapPOST("/configs", nil).
+			WithYAML(make(chan int)).
+			TryUnwrap()
+
+		assert.Error(t, err)
+	})
+}